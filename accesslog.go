@@ -0,0 +1,218 @@
+package ridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+)
+
+// AccessLogFormat is a format of access logs.
+type AccessLogFormat string
+
+const (
+	// AccessLogFormatCombined is the Apache combined log format followed by Lambda-aware fields.
+	AccessLogFormatCombined AccessLogFormat = "combined"
+	// AccessLogFormatJSON is a JSON object per line.
+	AccessLogFormatJSON AccessLogFormat = "json"
+	// AccessLogFormatLTSV is a Labeled Tab-separated Values per line.
+	AccessLogFormatLTSV AccessLogFormat = "ltsv"
+)
+
+// AccessLog represents a configuration of access logging.
+// When Ridge.AccessLog is set, ridge writes one line per request.
+type AccessLog struct {
+	// Format is a format of access logs. default is AccessLogFormatCombined.
+	Format AccessLogFormat
+	// Writer is a destination of access logs. default is os.Stdout.
+	Writer io.Writer
+
+	mu sync.Mutex
+}
+
+// AccessLogEntry represents an entry of access logs.
+type AccessLogEntry struct {
+	Time                time.Time
+	SourceIP            string
+	Method              string
+	URI                 string
+	Protocol            string
+	Status              int
+	Bytes               int64
+	Latency             time.Duration
+	Referer             string
+	UserAgent           string
+	AWSRequestID        string
+	APIGatewayRequestID string
+	TraceID             string
+	ColdStart           bool
+	InitDuration        time.Duration
+	PayloadVersion      string
+}
+
+func (l *AccessLog) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		rec := newResponseRecorder(w)
+		next.ServeHTTP(rec, req)
+		e := newAccessLogEntry(req, rec, start)
+		if err := l.write(e); err != nil {
+			log.Println("failed to write access log:", err)
+		}
+	})
+}
+
+func newAccessLogEntry(req *http.Request, rec *responseRecorder, start time.Time) *AccessLogEntry {
+	e := &AccessLogEntry{
+		Time:                start,
+		SourceIP:            sourceIP(req.RemoteAddr),
+		Method:              req.Method,
+		URI:                 req.RequestURI,
+		Protocol:            req.Proto,
+		Status:              rec.status,
+		Bytes:               rec.bytes,
+		Latency:             time.Since(start),
+		Referer:             req.Referer(),
+		UserAgent:           req.UserAgent(),
+		APIGatewayRequestID: req.Header.Get(RequestIDHeaderName),
+		TraceID:             traceIDFromRequest(req),
+		ColdStart:           IsColdStart(req.Context()),
+		InitDuration:        InitDuration(req.Context()),
+		PayloadVersion:      req.Header.Get(PayloadVersionHeaderName),
+	}
+	if e.URI == "" {
+		e.URI = req.URL.RequestURI()
+	}
+	if lc, ok := lambdacontext.FromContext(req.Context()); ok {
+		e.AWSRequestID = lc.AwsRequestID
+	} else {
+		e.AWSRequestID = req.Header.Get("Lambda-Runtime-Aws-Request-Id")
+	}
+	return e
+}
+
+// sourceIP returns a host part of addr. addr is a bare IP address on AWS Lambda, or host:port on net/http's server.
+func sourceIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+func (l *AccessLog) write(e *AccessLogEntry) error {
+	var line []byte
+	switch l.Format {
+	case AccessLogFormatJSON:
+		b, err := json.Marshal(e.fieldsMap())
+		if err != nil {
+			return err
+		}
+		line = append(b, '\n')
+	case AccessLogFormatLTSV:
+		line = []byte(e.ltsv())
+	case AccessLogFormatCombined, "":
+		line = []byte(e.combined())
+	default:
+		return fmt.Errorf("unknown access log format: %s", l.Format)
+	}
+	w := l.Writer
+	if w == nil {
+		w = os.Stdout
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err := w.Write(line)
+	return err
+}
+
+// fields returns labels and values of the entry in the output order.
+func (e *AccessLogEntry) fields() [][2]string {
+	return [][2]string{
+		{"time", e.Time.Format(time.RFC3339Nano)},
+		{"source_ip", e.SourceIP},
+		{"method", e.Method},
+		{"uri", e.URI},
+		{"protocol", e.Protocol},
+		{"status", strconv.Itoa(e.Status)},
+		{"bytes", strconv.FormatInt(e.Bytes, 10)},
+		{"latency", strconv.FormatFloat(e.Latency.Seconds(), 'f', 6, 64)},
+		{"referer", e.Referer},
+		{"user_agent", e.UserAgent},
+		{"aws_request_id", e.AWSRequestID},
+		{"apigw_request_id", e.APIGatewayRequestID},
+		{"trace_id", e.TraceID},
+		{"cold_start", strconv.FormatBool(e.ColdStart)},
+		{"init_duration", strconv.FormatFloat(e.InitDuration.Seconds(), 'f', 6, 64)},
+		{"payload_version", e.PayloadVersion},
+	}
+}
+
+func (e *AccessLogEntry) fieldsMap() map[string]interface{} {
+	return map[string]interface{}{
+		"time":             e.Time.Format(time.RFC3339Nano),
+		"source_ip":        e.SourceIP,
+		"method":           e.Method,
+		"uri":              e.URI,
+		"protocol":         e.Protocol,
+		"status":           e.Status,
+		"bytes":            e.Bytes,
+		"latency":          e.Latency.Seconds(),
+		"referer":          e.Referer,
+		"user_agent":       e.UserAgent,
+		"aws_request_id":   e.AWSRequestID,
+		"apigw_request_id": e.APIGatewayRequestID,
+		"trace_id":         e.TraceID,
+		"cold_start":       e.ColdStart,
+		"init_duration":    e.InitDuration.Seconds(),
+		"payload_version":  e.PayloadVersion,
+	}
+}
+
+// ltsvValueReplacer replaces separators of LTSV in values.
+var ltsvValueReplacer = strings.NewReplacer("\t", " ", "\n", " ")
+
+func (e *AccessLogEntry) ltsv() string {
+	fields := e.fields()
+	s := make([]string, 0, len(fields))
+	for _, f := range fields {
+		v := ltsvValueReplacer.Replace(f[1])
+		s = append(s, f[0]+":"+v)
+	}
+	return strings.Join(s, "\t") + "\n"
+}
+
+// combined returns the entry in the Apache combined log format,
+// followed by latency, AWS request ID, API Gateway request ID, cold start flag, payload version and trace ID.
+func (e *AccessLogEntry) combined() string {
+	return fmt.Sprintf("%s - - [%s] %q %d %d %q %q %.6f %s %s %t %s %s\n",
+		orHyphen(e.SourceIP),
+		e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		e.Method+" "+e.URI+" "+e.Protocol,
+		e.Status,
+		e.Bytes,
+		orHyphen(e.Referer),
+		orHyphen(e.UserAgent),
+		e.Latency.Seconds(),
+		orHyphen(e.AWSRequestID),
+		orHyphen(e.APIGatewayRequestID),
+		e.ColdStart,
+		orHyphen(e.PayloadVersion),
+		orHyphen(e.TraceID),
+	)
+}
+
+func orHyphen(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,121 @@
+package ridge_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/fujiwara/ridge"
+)
+
+func newAccessLogRidge(format ridge.AccessLogFormat, buf *bytes.Buffer) *ridge.Ridge {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, "Hello World")
+	})
+	r := ridge.New(":8080", "/", mux)
+	r.AccessLog = &ridge.AccessLog{Format: format, Writer: buf}
+	return r
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	ridge.ResetColdStart()
+	var buf bytes.Buffer
+	r := newAccessLogRidge(ridge.AccessLogFormatJSON, &buf)
+
+	payload, err := os.ReadFile("test/get-v2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := ridge.NewRequest(json.RawMessage(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.URL.Path = "/hello"
+	req.RequestURI = "/hello"
+	ctx := lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{AwsRequestID: "aws-request-id"})
+	w := ridge.NewResponseWriter()
+	r.Handler().ServeHTTP(w, req.WithContext(ctx))
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("failed to decode access log %q: %s", buf.String(), err)
+	}
+	expected := map[string]interface{}{
+		"source_ip":        "203.0.113.1",
+		"method":           "GET",
+		"uri":              "/hello",
+		"status":           float64(http.StatusAccepted),
+		"bytes":            float64(len("Hello World")),
+		"user_agent":       "curl/7.68.0",
+		"aws_request_id":   "aws-request-id",
+		"apigw_request_id": "Jl6rIhtwNjMEJLQ=",
+		"cold_start":       true,
+		"payload_version":  "2.0",
+	}
+	for k, v := range expected {
+		if entry[k] != v {
+			t.Errorf("%s: expected %v, got %v", k, v, entry[k])
+		}
+	}
+}
+
+func TestAccessLogStreaming(t *testing.T) {
+	var buf bytes.Buffer
+	r := newAccessLogRidge(ridge.AccessLogFormatLTSV, &buf)
+
+	req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+	w := ridge.NewStreamingResponseWriter()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer w.Close()
+		r.Handler().ServeHTTP(w, req)
+	}()
+	w.Wait()
+	body, _ := io.ReadAll(w.Response().Body)
+	<-done
+	if string(body) != "Hello World" {
+		t.Errorf("unexpected body: %s", body)
+	}
+	line := buf.String()
+	for _, f := range []string{"status:202", "bytes:11", "uri:/hello", "source_ip:192.0.2.1"} {
+		if !strings.Contains(line, f) {
+			t.Errorf("access log %q does not contain %q", line, f)
+		}
+	}
+}
+
+func TestAccessLogCombined(t *testing.T) {
+	var buf bytes.Buffer
+	r := newAccessLogRidge(ridge.AccessLogFormatCombined, &buf)
+
+	ts := httptest.NewServer(r.Handler())
+	defer ts.Close()
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/hello?foo=bar", nil)
+	req.Header.Set("User-Agent", "ridge-test")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+
+	line := buf.String()
+	if !strings.HasPrefix(line, "127.0.0.1 - - [") {
+		t.Errorf("unexpected access log: %s", line)
+	}
+	if !strings.Contains(line, `"GET /hello?foo=bar HTTP/1.1" 202 11 "-" "ridge-test" `) {
+		t.Errorf("unexpected access log: %s", line)
+	}
+}
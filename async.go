@@ -0,0 +1,85 @@
+package ridge
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+)
+
+// maxTrackedInvocations is a max number of request IDs to count attempts of asynchronous invocations.
+const maxTrackedInvocations = 1024
+
+// AsyncInvocation represents an asynchronous invocation (InvocationType: Event).
+type AsyncInvocation struct {
+	// RequestID is the AWS request ID. Retries of an asynchronous invocation have the same request ID.
+	RequestID string
+	// Attempt is the number of attempts of the invocation starting from 1.
+	// Retries delivered to other execution environments are not counted.
+	Attempt int
+}
+
+// IsRetry reports whether the invocation is a retry of a failed one.
+func (a AsyncInvocation) IsRetry() bool {
+	return a.Attempt > 1
+}
+
+type asyncInvocationKey struct{}
+
+// AsyncInvocationFromContext returns the asynchronous invocation of the request.
+// It returns false when the request is not an asynchronous invocation.
+func AsyncInvocationFromContext(ctx context.Context) (AsyncInvocation, bool) {
+	a, ok := ctx.Value(asyncInvocationKey{}).(AsyncInvocation)
+	return a, ok
+}
+
+func (r *Ridge) withAsyncInvocation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !r.AsyncInvocation {
+			next.ServeHTTP(w, req)
+			return
+		}
+		id := awsRequestID(req)
+		a := AsyncInvocation{RequestID: id, Attempt: r.attempts.add(id)}
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), asyncInvocationKey{}, a)))
+	})
+}
+
+// awsRequestID returns the AWS request ID of the invocation.
+// It returns an empty string when the request is not an invocation on AWS Lambda runtime.
+// The Lambda-Runtime-Aws-Request-Id header is not used, because clients can send it.
+func awsRequestID(req *http.Request) string {
+	if lc, ok := lambdacontext.FromContext(req.Context()); ok {
+		return lc.AwsRequestID
+	}
+	return ""
+}
+
+// attemptCounter counts attempts per request ID in the execution environment.
+// Old request IDs are forgotten when it tracks more than maxTrackedInvocations.
+type attemptCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+	order  []string
+}
+
+func (c *attemptCounter) add(id string) int {
+	if id == "" {
+		return 1
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[string]int)
+	}
+	if _, ok := c.counts[id]; !ok {
+		if len(c.order) >= maxTrackedInvocations {
+			delete(c.counts, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.order = append(c.order, id)
+	}
+	c.counts[id]++
+	return c.counts[id]
+}
@@ -0,0 +1,75 @@
+package ridge
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type originalPathKey struct{}
+
+// OriginalPath returns the request path before the stage name or the base path is stripped.
+// It returns an empty string when the path is not stripped.
+func OriginalPath(ctx context.Context) string {
+	p, _ := ctx.Value(originalPathKey{}).(string)
+	return p
+}
+
+// withStripBasePath strips the stage name (when StripStage is true) and the first matched BasePaths from the request path.
+func (r *Ridge) withStripBasePath(next http.Handler) http.Handler {
+	if !r.StripStage && len(r.BasePaths) == 0 {
+		return next
+	}
+	// the longest base path matches first.
+	basePaths := make([]string, 0, len(r.BasePaths))
+	for _, p := range r.BasePaths {
+		if p = strings.TrimSuffix(p, "/"); p != "" {
+			basePaths = append(basePaths, p)
+		}
+	}
+	sort.Slice(basePaths, func(i, j int) bool { return len(basePaths[i]) > len(basePaths[j]) })
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		p, rp := req.URL.Path, req.URL.RawPath
+		if r.StripStage {
+			if ec, ok := eventContextFrom(req.Context()); ok && ec.stage != "" && ec.stage != "$default" {
+				p, rp = stripPathPrefix(p, rp, "/"+ec.stage)
+			}
+		}
+		for _, bp := range basePaths {
+			if sp, srp := stripPathPrefix(p, rp, bp); sp != p {
+				p, rp = sp, srp
+				break
+			}
+		}
+		if p == req.URL.Path {
+			next.ServeHTTP(w, req)
+			return
+		}
+		ctx := context.WithValue(req.Context(), originalPathKey{}, req.URL.Path)
+		r2 := req.Clone(ctx)
+		r2.URL = new(url.URL)
+		*r2.URL = *req.URL
+		r2.URL.Path = p
+		r2.URL.RawPath = rp
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// stripPathPrefix strips prefix from the path when the path is the prefix or starts with the prefix followed by "/".
+// The stripped path always starts with "/".
+func stripPathPrefix(p, rp, prefix string) (string, string) {
+	if p != prefix && !strings.HasPrefix(p, prefix+"/") {
+		return p, rp
+	}
+	p = "/" + strings.TrimPrefix(strings.TrimPrefix(p, prefix), "/")
+	if strings.HasPrefix(rp, prefix) {
+		rp = "/" + strings.TrimPrefix(strings.TrimPrefix(rp, prefix), "/")
+	} else {
+		// the escaped path is derived from the path.
+		rp = ""
+	}
+	return p, rp
+}
@@ -0,0 +1,77 @@
+package ridge_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/fujiwara/ridge"
+)
+
+func pathEchoHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", r.URL.Path, ridge.OriginalPath(r.Context()))
+	})
+}
+
+func invokeV2(t *testing.T, r *ridge.Ridge, stage, rawPath string) string {
+	t.Helper()
+	event := ridge.RequestV2{
+		Version: "2.0",
+		RawPath: rawPath,
+		Headers: map[string]string{"host": "api.example.com"},
+	}
+	event.RequestContext.HTTP.Method = http.MethodGet
+	event.RequestContext.Stage = stage
+	b, _ := json.Marshal(event)
+	res, err := r.Invoke(context.Background(), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.(ridge.Response).Body
+}
+
+func TestStripStage(t *testing.T) {
+	r := ridge.New("", "/", pathEchoHandler())
+	r.StripStage = true
+	tests := []struct {
+		stage, path, expected string
+	}{
+		{"prod", "/prod/users", "/users /prod/users"},
+		{"prod", "/prod", "/ /prod"},
+		{"prod", "/production/users", "/production/users "},
+		{"$default", "/$default/users", "/$default/users "},
+		{"", "/users", "/users "},
+	}
+	for _, tt := range tests {
+		if body := invokeV2(t, r, tt.stage, tt.path); body != tt.expected {
+			t.Errorf("stage %q path %s: unexpected %q, expected %q", tt.stage, tt.path, body, tt.expected)
+		}
+	}
+
+	r.StripStage = false
+	if body := invokeV2(t, r, "prod", "/prod/users"); body != "/prod/users " {
+		t.Errorf("the stage must not be stripped: %q", body)
+	}
+}
+
+func TestBasePaths(t *testing.T) {
+	r := ridge.New("", "/", pathEchoHandler())
+	r.StripStage = true
+	r.BasePaths = []string{"/v1", "/v1/admin/"}
+	tests := []struct {
+		stage, path, expected string
+	}{
+		{"$default", "/v1/users", "/users /v1/users"},
+		{"$default", "/v1/admin/users", "/users /v1/admin/users"},
+		{"$default", "/users", "/users "},
+		{"prod", "/prod/v1/users", "/users /prod/v1/users"},
+	}
+	for _, tt := range tests {
+		if body := invokeV2(t, r, tt.stage, tt.path); body != tt.expected {
+			t.Errorf("stage %q path %s: unexpected %q, expected %q", tt.stage, tt.path, body, tt.expected)
+		}
+	}
+}
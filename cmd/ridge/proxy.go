@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fujiwara/ridge"
+)
+
+const proxyUsage = `Usage: ridge proxy [options] [--] [command [args...]]
+
+ridge proxy runs as a Lambda handler (or an extension, or a local server), and forwards requests
+converted from events to the HTTP server at -target.
+When a command is given, it is spawned with PORT environment variable, and ridge waits for it to be ready.
+
+Options:
+`
+
+type proxyOptions struct {
+	target           string
+	port             int
+	address          string
+	prefix           string
+	readinessPath    string
+	readinessStatus  int
+	readinessTimeout time.Duration
+	drainDelay       time.Duration
+	shutdownTimeout  time.Duration
+	streaming        bool
+}
+
+func runProxy(ctx context.Context, args []string) error {
+	var opts proxyOptions
+	fs := flag.NewFlagSet("proxy", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), proxyUsage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opts.target, "target", "", "URL of the HTTP server (default http://127.0.0.1:<port>)")
+	fs.IntVar(&opts.port, "port", 8080, "port of the HTTP server, passed to the command as PORT")
+	fs.StringVar(&opts.address, "address", ":8000", "address to listen on when not running as a Lambda handler")
+	fs.StringVar(&opts.prefix, "prefix", "/", "path prefix to serve")
+	fs.StringVar(&opts.readinessPath, "readiness-path", "/", "path of the HTTP server to check readiness")
+	fs.IntVar(&opts.readinessStatus, "readiness-status", 0, "status code of -readiness-path to be ready (default any status other than 5xx)")
+	fs.DurationVar(&opts.readinessTimeout, "readiness-timeout", 10*time.Second, "timeout to wait for the HTTP server to be ready")
+	fs.DurationVar(&opts.drainDelay, "drain-delay", 0, "time to wait for load balancers to deregister the target on SIGTERM when not running as a Lambda handler")
+	fs.DurationVar(&opts.shutdownTimeout, "shutdown-timeout", 5*time.Second, "timeout to wait for the command to exit after SIGTERM")
+	fs.BoolVar(&opts.streaming, "streaming", false, "enable the streaming response mode (or set "+ridge.StreamingResponseEnv+")")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	if opts.target == "" {
+		opts.target = "http://127.0.0.1:" + strconv.Itoa(opts.port)
+	}
+	target, err := url.Parse(opts.target)
+	if err != nil || target.Scheme == "" || target.Host == "" {
+		return fmt.Errorf("invalid target: %q", opts.target)
+	}
+
+	var child *childProcess
+	if fs.NArg() > 0 {
+		child, err = startChild(fs.Args(), opts.port)
+		if err != nil {
+			return err
+		}
+		defer child.stop(opts.shutdownTimeout)
+	}
+	readiness := target.ResolveReference(&url.URL{Path: opts.readinessPath})
+	// ridge handles SIGTERM after it starts serving. Until then, SIGTERM stops waiting.
+	readyCtx, stop := signal.NotifyContext(ctx, syscall.SIGTERM)
+	err = waitReady(readyCtx, readiness.String(), opts.readinessStatus, opts.readinessTimeout, child.exited())
+	stop()
+	if err != nil {
+		return err
+	}
+	log.Printf("%s is ready", target)
+
+	r := ridge.New(opts.address, opts.prefix, ridge.NewReverseProxyHandler(target))
+	r.StreamingResponse = opts.streaming
+	r.DrainOnSIGTERM = true
+	r.DrainDelay = opts.drainDelay
+	errCh := make(chan error, 1)
+	go func() { errCh <- r.Serve(ctx) }()
+	select {
+	case err := <-errCh:
+		return err
+	case <-child.exited():
+		// the process exits to let the Lambda runtime restart the execution environment.
+		return fmt.Errorf("command exited: %v", child.err)
+	}
+}
+
+// childProcess is a supervised process of the HTTP server.
+type childProcess struct {
+	cmd  *exec.Cmd
+	done chan struct{}
+	err  error
+}
+
+// childEnv returns environment variables of the child process.
+// Variables of the Lambda runtime API are removed, not to make the child (e.g. a ridge application) act as a Lambda handler.
+func childEnv(port int) []string {
+	env := make([]string, 0, len(os.Environ())+1)
+	for _, e := range os.Environ() {
+		if strings.HasPrefix(e, "AWS_LAMBDA_RUNTIME_API=") || strings.HasPrefix(e, "_HANDLER=") || strings.HasPrefix(e, "PORT=") {
+			continue
+		}
+		env = append(env, e)
+	}
+	return append(env, "PORT="+strconv.Itoa(port))
+}
+
+func startChild(args []string, port int) (*childProcess, error) {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = childEnv(port)
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", args[0], err)
+	}
+	log.Printf("started %s (pid %d)", args[0], cmd.Process.Pid)
+	c := &childProcess{cmd: cmd, done: make(chan struct{})}
+	go func() {
+		c.err = cmd.Wait()
+		log.Printf("%s exited: %v", args[0], cmd.ProcessState)
+		close(c.done)
+	}()
+	return c, nil
+}
+
+// exited returns a channel closed when the process exited. It returns nil (never closed) for a nil process.
+func (c *childProcess) exited() <-chan struct{} {
+	if c == nil {
+		return nil
+	}
+	return c.done
+}
+
+// stop sends SIGTERM to the process, and kills it when it does not exit within timeout.
+func (c *childProcess) stop(timeout time.Duration) {
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+	c.cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case <-c.done:
+	case <-time.After(timeout):
+		log.Printf("killing pid %d", c.cmd.Process.Pid)
+		c.cmd.Process.Kill()
+		<-c.done
+	}
+}
+
+// waitReady waits for the HTTP server to respond at url with status.
+// When status is 0, any status other than 5xx is ready.
+func waitReady(ctx context.Context, url string, status int, timeout time.Duration, exited <-chan struct{}) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	client := &http.Client{Timeout: time.Second}
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		if res, err := client.Do(req); err == nil {
+			res.Body.Close()
+			if (status == 0 && res.StatusCode < 500) || res.StatusCode == status {
+				return nil
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-exited:
+			return errors.New("command exited before it is ready")
+		case <-ctx.Done():
+			return fmt.Errorf("%s is not ready: %w", url, ctx.Err())
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// runs as a child process of TestRunProxy.
+	if os.Getenv("RIDGE_TEST_CHILD") != "" {
+		http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "child %s %s", r.URL.Path, r.Header.Get("X-Forwarded-Host"))
+		})
+		if err := http.ListenAndServe("127.0.0.1:"+os.Getenv("PORT"), nil); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+	os.Exit(m.Run())
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestWaitReady(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+	if err := waitReady(context.Background(), ts.URL, 0, 5*time.Second, nil); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Errorf("unexpected calls: %d", calls)
+	}
+
+	url := "http://127.0.0.1:" + strconv.Itoa(freePort(t))
+	if err := waitReady(context.Background(), url, 0, 300*time.Millisecond, nil); err == nil {
+		t.Error("expected a timeout error")
+	}
+}
+
+func TestWaitReadyStatus(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	if err := waitReady(context.Background(), ts.URL, http.StatusOK, 5*time.Second, nil); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Errorf("unexpected calls: %d", calls)
+	}
+}
+
+func TestWaitReadyChildExited(t *testing.T) {
+	child, err := startChild([]string{"sh", "-c", "exit 3"}, freePort(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "http://127.0.0.1:" + strconv.Itoa(freePort(t))
+	if err := waitReady(context.Background(), url, 0, 5*time.Second, child.exited()); err == nil {
+		t.Error("expected an error for the exited command")
+	}
+}
+
+func TestRunProxy(t *testing.T) {
+	t.Setenv("RIDGE_TEST_CHILD", "1")
+	port := freePort(t)
+	address := "127.0.0.1:" + strconv.Itoa(freePort(t))
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- runProxy(ctx, []string{"-port", strconv.Itoa(port), "-address", address, "--", os.Args[0]})
+	}()
+	defer func() {
+		cancel()
+		if err := <-errCh; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	var res *http.Response
+	var err error
+	for i := 0; i < 100; i++ {
+		if res, err = http.Get("http://" + address + "/hello"); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, _ := io.ReadAll(res.Body)
+	if string(b) != "child /hello "+address {
+		t.Errorf("unexpected body: %s", b)
+	}
+}
+
+func TestRunProxySIGTERM(t *testing.T) {
+	t.Setenv("RIDGE_TEST_CHILD", "1")
+	// keeps the test process alive when SIGTERM arrives before ridge handles it.
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	port := freePort(t)
+	address := "127.0.0.1:" + strconv.Itoa(freePort(t))
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- runProxy(context.Background(), []string{"-port", strconv.Itoa(port), "-address", address, "--", os.Args[0]})
+	}()
+	for i := 0; i < 100; i++ {
+		if res, err := http.Get("http://" + address + "/"); err == nil {
+			res.Body.Close()
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	syscall.Kill(os.Getpid(), syscall.SIGTERM)
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("runProxy does not return after SIGTERM")
+	}
+}
@@ -0,0 +1,51 @@
+package ridge
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// processStartedAt is an approximate time when the process started.
+var processStartedAt = time.Now()
+
+// invoked is set to 1 after the first request in the process is served.
+var invoked uint32
+
+type coldStartKey struct{}
+
+type coldStartInfo struct {
+	cold         bool
+	initDuration time.Duration
+}
+
+func (r *Ridge) withColdStart(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		info := coldStartInfo{cold: atomic.CompareAndSwapUint32(&invoked, 0, 1)}
+		if info.cold {
+			info.initDuration = time.Since(processStartedAt)
+		}
+		if name := r.ColdStartHeader; name != "" {
+			v := strconv.FormatBool(info.cold)
+			req.Header.Set(name, v)
+			w.Header().Set(name, v)
+		}
+		ctx := context.WithValue(req.Context(), coldStartKey{}, info)
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+// IsColdStart returns true if the request is the first one in the execution environment (process).
+func IsColdStart(ctx context.Context) bool {
+	info, _ := ctx.Value(coldStartKey{}).(coldStartInfo)
+	return info.cold
+}
+
+// InitDuration returns a duration from the process start to the first request.
+// It returns 0 if the request is not a cold start.
+func InitDuration(ctx context.Context) time.Duration {
+	info, _ := ctx.Value(coldStartKey{}).(coldStartInfo)
+	return info.initDuration
+}
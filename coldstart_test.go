@@ -0,0 +1,38 @@
+package ridge_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fujiwara/ridge"
+)
+
+func TestColdStart(t *testing.T) {
+	ridge.ResetColdStart()
+	var cold []bool
+	var initDurations []time.Duration
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cold = append(cold, ridge.IsColdStart(r.Context()))
+		initDurations = append(initDurations, ridge.InitDuration(r.Context()))
+	})
+	r := ridge.New(":8080", "/", h)
+	r.ColdStartHeader = "X-Cold-Start"
+
+	var headers []string
+	for i := 0; i < 2; i++ {
+		w := ridge.NewResponseWriter()
+		r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		headers = append(headers, w.Header().Get("X-Cold-Start"))
+	}
+	if !cold[0] || cold[1] {
+		t.Errorf("unexpected cold start flags: %v", cold)
+	}
+	if initDurations[0] <= 0 || initDurations[1] != 0 {
+		t.Errorf("unexpected init durations: %v", initDurations)
+	}
+	if headers[0] != "true" || headers[1] != "false" {
+		t.Errorf("unexpected cold start headers: %v", headers)
+	}
+}
@@ -0,0 +1,73 @@
+package ridge
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+func (r *Ridge) isDraining() bool {
+	return atomic.LoadInt32(&r.draining) == 1
+}
+
+// signalGracePeriod is a time to wait for a signal delivered to ch after the context is cancelled by the same signal.
+const signalGracePeriod = 50 * time.Millisecond
+
+// receivedSignal reports whether a signal is received on ch.
+// signal.NotifyContext cancels the context in another goroutine, so the signal may arrive on ch a little later.
+func receivedSignal(ch <-chan os.Signal) bool {
+	t := time.NewTimer(signalGracePeriod)
+	defer t.Stop()
+	select {
+	case <-ch:
+		return true
+	case <-t.C:
+		return false
+	}
+}
+
+// shutdown shuts down the server gracefully.
+// When drain is true, it turns the readiness endpoint to 503, disables keep-alives to make clients reconnect to other targets,
+// and waits DrainDelay before shutting down, for load balancers to deregister the target.
+// The context passed to Serve may be already cancelled (e.g. by the same SIGTERM), so it is not used for any phases.
+func (r *Ridge) shutdown(srv *http.Server, drain bool) error {
+	timeout := r.ShutdownTimeout
+	if timeout == 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	start := time.Now()
+	atomic.StoreInt32(&r.draining, 1)
+	if drain && r.DrainDelay > 0 {
+		log.Printf("draining local httpd: budget %s (deregistration delay %s, shutdown timeout %s)", r.DrainDelay+timeout, r.DrainDelay, timeout)
+		srv.SetKeepAlivesEnabled(false)
+		time.Sleep(r.DrainDelay)
+		log.Printf("deregistration delay completed in %s", time.Since(start))
+	}
+
+	phase := time.Now()
+	log.Printf("shutting down local httpd: waiting for in-flight requests up to %s", timeout)
+	sctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var err error
+	if err = srv.Shutdown(sctx); err != nil {
+		srv.Close()
+		err = fmt.Errorf("failed to shut down local httpd gracefully: %w", err)
+	}
+	log.Printf("local httpd stopped in %s", time.Since(phase))
+
+	if len(r.ShutdownHooks) > 0 {
+		phase = time.Now()
+		hctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		for _, hook := range r.ShutdownHooks {
+			hook(hctx)
+		}
+		log.Printf("shutdown hooks completed in %s", time.Since(phase))
+	}
+	log.Printf("shutdown completed in %s", time.Since(start))
+	return err
+}
@@ -0,0 +1,107 @@
+package ridge_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/fujiwara/ridge"
+)
+
+func TestDrainOnSIGTERM(t *testing.T) {
+	r := ridge.New("127.0.0.1:0", "/", protoHandler())
+	r.DrainOnSIGTERM = true
+	r.DrainDelay = 300 * time.Millisecond
+	r.ReadinessPath = "/readyz"
+	var termCalled, hookCalled bool
+	r.TermHandler = func() { termCalled = true }
+	r.ShutdownHooks = []func(context.Context){
+		func(ctx context.Context) { hookCalled = true },
+	}
+	ready := make(chan net.Addr, 1)
+	r.OnReady = func(addr net.Addr) { ready <- addr }
+	errCh := make(chan error, 1)
+	go func() { errCh <- r.Serve(context.Background()) }()
+	base := "http://" + (<-ready).String()
+
+	if code := getStatus(t, base+"/readyz"); code != http.StatusOK {
+		t.Errorf("unexpected readiness status before SIGTERM: %d", code)
+	}
+	p, _ := os.FindProcess(os.Getpid())
+	if err := p.Signal(syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// the server is still serving during the deregistration delay
+	if code := getStatus(t, base+"/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("unexpected readiness status while draining: %d", code)
+	}
+	if code := getStatus(t, base+"/"); code != http.StatusOK {
+		t.Errorf("unexpected status while draining: %d", code)
+	}
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve does not return after draining")
+	}
+	if !termCalled || !hookCalled {
+		t.Errorf("TermHandler called %v, shutdown hook called %v", termCalled, hookCalled)
+	}
+}
+
+func getStatus(t *testing.T, url string) int {
+	t.Helper()
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return res.StatusCode
+}
+
+func TestDrainOnSIGTERMWithNotifyContext(t *testing.T) {
+	r := ridge.New("127.0.0.1:0", "/", protoHandler())
+	r.DrainOnSIGTERM = true
+	r.DrainDelay = 300 * time.Millisecond
+	r.ReadinessPath = "/readyz"
+	ready := make(chan net.Addr, 1)
+	r.OnReady = func(addr net.Addr) { ready <- addr }
+	// the context is cancelled by the same SIGTERM.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM)
+	defer stop()
+	errCh := make(chan error, 1)
+	go func() { errCh <- r.Serve(ctx) }()
+	base := "http://" + (<-ready).String()
+
+	start := time.Now()
+	p, _ := os.FindProcess(os.Getpid())
+	if err := p.Signal(syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	<-ctx.Done()
+	time.Sleep(100 * time.Millisecond)
+	if code := getStatus(t, base+"/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("unexpected readiness status while draining: %d", code)
+	}
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve does not return after draining")
+	}
+	if elapsed := time.Since(start); elapsed < r.DrainDelay {
+		t.Errorf("deregistration delay is cut short: %s", elapsed)
+	}
+}
@@ -0,0 +1,46 @@
+package ridge
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupportedPayloadVersion is returned when the payload version of an event is not supported.
+var ErrUnsupportedPayloadVersion = errors.New("unsupported payload version")
+
+// ErrInvalidEvent is returned when an event cannot be decoded.
+// Errors wrapping the field name (*InvalidEventError) match it with errors.Is.
+var ErrInvalidEvent = errors.New("invalid event")
+
+// InvalidEventError represents an error of decoding a field of an event.
+type InvalidEventError struct {
+	Field string
+	Err   error
+}
+
+func (e *InvalidEventError) Error() string {
+	return fmt.Sprintf("invalid event: %s: %s", e.Field, e.Err)
+}
+
+func (e *InvalidEventError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrInvalidEvent.
+func (e *InvalidEventError) Is(target error) bool {
+	return target == ErrInvalidEvent
+}
+
+type unsupportedPayloadVersionError string
+
+func (e unsupportedPayloadVersionError) Error() string {
+	return fmt.Sprintf("payload Version %s is not supported", string(e))
+}
+
+func (e unsupportedPayloadVersionError) Is(target error) bool {
+	return target == ErrUnsupportedPayloadVersion
+}
+
+func invalidEvent(field string, err error) error {
+	return &InvalidEventError{Field: field, Err: err}
+}
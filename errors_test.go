@@ -0,0 +1,80 @@
+package ridge_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fujiwara/ridge"
+)
+
+var invalidEvents = []struct {
+	name  string
+	event string
+	field string
+}{
+	{"broken json", `{"version":`, "event"},
+	{"type mismatch", `{"version":"2.0","rawPath":1}`, "rawPath"},
+	{"invalid escape in path", `{"version":"2.0","rawPath":"/%zz","requestContext":{"http":{"method":"GET"}}}`, "rawPath"},
+	{"relative path", `{"httpMethod":"GET","path":"foo"}`, "path"},
+	{"empty method", `{"path":"/"}`, "httpMethod"},
+	{"malformed base64 body", `{"httpMethod":"POST","path":"/","body":"!!!","isBase64Encoded":true}`, "body"},
+}
+
+func TestInvalidEventError(t *testing.T) {
+	for _, tt := range invalidEvents {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ridge.NewRequest(json.RawMessage(tt.event))
+			if !errors.Is(err, ridge.ErrInvalidEvent) {
+				t.Fatalf("expected ErrInvalidEvent, got %v", err)
+			}
+			var ie *ridge.InvalidEventError
+			if !errors.As(err, &ie) {
+				t.Fatalf("expected InvalidEventError, got %T", err)
+			}
+			if ie.Field != tt.field {
+				t.Errorf("expected field %s, got %s", tt.field, ie.Field)
+			}
+		})
+	}
+}
+
+func TestUnsupportedPayloadVersionError(t *testing.T) {
+	_, err := ridge.NewRequest(json.RawMessage(`{"version":"3.0"}`))
+	if !errors.Is(err, ridge.ErrUnsupportedPayloadVersion) {
+		t.Errorf("expected ErrUnsupportedPayloadVersion, got %v", err)
+	}
+}
+
+func TestBadRequestOnInvalidEvent(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		r := ridge.New(":8080", "/", http.NotFoundHandler())
+		r.StreamingResponse = streaming
+		if _, err := r.Invoke(context.Background(), json.RawMessage(`{"path":"/"}`)); err == nil {
+			t.Error("expected invocation error")
+		}
+
+		r.BadRequestOnInvalidEvent = true
+		res, err := r.Invoke(context.Background(), json.RawMessage(`{"path":"/"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var code int
+		switch res := res.(type) {
+		case ridge.Response:
+			code = res.StatusCode
+		case *events.LambdaFunctionURLStreamingResponse:
+			code = res.StatusCode
+			io.Copy(io.Discard, res.Body)
+		default:
+			t.Fatalf("unexpected response type: %T", res)
+		}
+		if code != http.StatusBadRequest {
+			t.Errorf("unexpected status code: %d", code)
+		}
+	}
+}
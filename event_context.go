@@ -0,0 +1,42 @@
+package ridge
+
+import (
+	"context"
+	"net/http"
+)
+
+// eventContext represents information of the invocation event which is not a part of the HTTP request.
+type eventContext struct {
+	stage      string
+	domainName string
+	authorizer map[string]interface{}
+}
+
+type eventContextKey struct{}
+
+func withEventContext(req *http.Request, ec eventContext) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), eventContextKey{}, ec))
+}
+
+func eventContextFrom(ctx context.Context) (eventContext, bool) {
+	ec, ok := ctx.Value(eventContextKey{}).(eventContext)
+	return ec, ok
+}
+
+// AuthorizerFromContext returns the authorizer context of the event (requestContext.authorizer) set by API Gateway.
+// It returns false if the request has no authorizer context, e.g. for ALB or the local server.
+func AuthorizerFromContext(ctx context.Context) (map[string]interface{}, bool) {
+	ec, ok := eventContextFrom(ctx)
+	if !ok || ec.authorizer == nil {
+		return nil, false
+	}
+	return ec.authorizer, true
+}
+
+// inheritEventContext copies the event context of the request created by RequestBuilder to ctx.
+func inheritEventContext(ctx context.Context, req *http.Request) context.Context {
+	if ec, ok := eventContextFrom(req.Context()); ok {
+		return context.WithValue(ctx, eventContextKey{}, ec)
+	}
+	return ctx
+}
@@ -1,5 +1,35 @@
 package ridge
 
+import (
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
 func (r *Ridge) SetStreamingResponse() {
 	r.setStreamingResponse()
 }
+
+func (r *Ridge) Handler() http.Handler {
+	return r.handler()
+}
+
+func ResetColdStart() {
+	atomic.StoreUint32(&invoked, 0)
+}
+
+func (c *ProxyProtocolConfig) Listener(l net.Listener) (net.Listener, error) {
+	return c.listener(l)
+}
+
+var WithProxyProtocolConn = withProxyProtocolConn
+
+func (r *Ridge) Listen() (net.Listener, error) {
+	return r.listen()
+}
+
+func SetListenFdsStart(fd int) (restore func()) {
+	orig := listenFdsStart
+	listenFdsStart = fd
+	return func() { listenFdsStart = orig }
+}
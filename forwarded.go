@@ -0,0 +1,134 @@
+package ridge
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// remoteAddr returns ip as host:port, like RemoteAddr of requests on net/http's server.
+// Events do not have the port of the client, so the port is always 0.
+func remoteAddr(ip string) string {
+	ip = strings.TrimSpace(ip)
+	if ip == "" {
+		return ""
+	}
+	return net.JoinHostPort(ip, "0")
+}
+
+// lastForwardedFor returns the last address of X-Forwarded-For, which is appended by the nearest proxy.
+func lastForwardedFor(h http.Header) string {
+	values := h.Values("X-Forwarded-For")
+	if len(values) == 0 {
+		return ""
+	}
+	addrs := strings.Split(values[len(values)-1], ",")
+	return strings.TrimSpace(addrs[len(addrs)-1])
+}
+
+// forwardedProto returns the scheme in X-Forwarded-Proto, or def if not present.
+func forwardedProto(h http.Header, def string) string {
+	if proto := h.Get("X-Forwarded-Proto"); proto != "" {
+		return strings.ToLower(strings.TrimSpace(strings.Split(proto, ",")[0]))
+	}
+	return def
+}
+
+// setRequestOrigin sets URL.Scheme, URL.Host and TLS of req as the client requested.
+func setRequestOrigin(req *http.Request, scheme string) {
+	req.URL.Scheme = scheme
+	req.URL.Host = req.Host
+	if scheme == "https" && req.TLS == nil {
+		req.TLS = syntheticTLSState(req.Host)
+	}
+}
+
+// syntheticTLSState returns a connection state of TLS terminated by a proxy (API Gateway, ALB, etc.).
+// Only HandshakeComplete and ServerName are set.
+func syntheticTLSState(host string) *tls.ConnectionState {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return &tls.ConnectionState{
+		HandshakeComplete: true,
+		ServerName:        host,
+	}
+}
+
+// trustedProxies represents networks of proxies whose X-Forwarded-* headers are trusted.
+type trustedProxies []*net.IPNet
+
+func parseTrustedProxies(cidrs []string) (trustedProxies, error) {
+	nets := make(trustedProxies, 0, len(cidrs))
+	for _, c := range cidrs {
+		if !strings.Contains(c, "/") {
+			if ip := net.ParseIP(c); ip != nil && ip.To4() != nil {
+				c += "/32"
+			} else {
+				c += "/128"
+			}
+		}
+		_, ipnet, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy: %w", err)
+		}
+		nets = append(nets, ipnet)
+	}
+	return nets, nil
+}
+
+func (t trustedProxies) contains(addr string) bool {
+	ip := net.ParseIP(sourceIP(addr))
+	if ip == nil {
+		return false
+	}
+	for _, n := range t {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the nearest address in X-Forwarded-For which is not a trusted proxy.
+func (t trustedProxies) clientIP(h http.Header) string {
+	var addrs []string
+	for _, v := range h.Values("X-Forwarded-For") {
+		for _, a := range strings.Split(v, ",") {
+			if a = strings.TrimSpace(a); a != "" {
+				addrs = append(addrs, a)
+			}
+		}
+	}
+	for i := len(addrs) - 1; i >= 0; i-- {
+		if !t.contains(addrs[i]) || i == 0 {
+			return addrs[i]
+		}
+	}
+	return ""
+}
+
+// withOrigin sets URL.Scheme and URL.Host of requests on net/http's server in the same way as requests on AWS Lambda.
+// When the peer is a trusted proxy, X-Forwarded-For, X-Forwarded-Proto and X-Forwarded-Host are applied.
+func withOrigin(next http.Handler, proxies trustedProxies) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		scheme := "http"
+		if req.TLS != nil {
+			scheme = "https"
+		}
+		req = req.Clone(req.Context())
+		if len(proxies) > 0 && proxies.contains(req.RemoteAddr) {
+			if ip := proxies.clientIP(req.Header); ip != "" {
+				req.RemoteAddr = remoteAddr(ip)
+			}
+			if host := req.Header.Get("X-Forwarded-Host"); host != "" {
+				req.Host = host
+			}
+			scheme = forwardedProto(req.Header, scheme)
+		}
+		setRequestOrigin(req, scheme)
+		next.ServeHTTP(w, req)
+	})
+}
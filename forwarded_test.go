@@ -0,0 +1,142 @@
+package ridge_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/fujiwara/ridge"
+)
+
+func TestRequestOrigin(t *testing.T) {
+	tests := []struct {
+		file       string
+		remoteAddr string
+		url        string
+		tls        bool
+	}{
+		{"test/get-v1.json", "203.0.113.1:0", "https://abcdefg.execute-api.ap-northeast-1.example.com/", true},
+		{"test/get-v2.json", "203.0.113.1:0", "https://abcdefg.execute-api.ap-northeast-1.amazonaws.com/", true},
+		{"test/get-lattice.json", "10.0.2.100:0", "http://example-0123456789abcdef.7d67968.vpc-lattice-svcs.us-east-1.on.aws/", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			b, err := os.ReadFile(tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := ridge.NewRequest(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.RemoteAddr != tt.remoteAddr {
+				t.Errorf("RemoteAddr: %s is not expected", r.RemoteAddr)
+			}
+			if _, _, err := net.SplitHostPort(r.RemoteAddr); err != nil {
+				t.Errorf("RemoteAddr: %s", err)
+			}
+			if u := r.URL.Scheme + "://" + r.URL.Host + "/"; u != tt.url {
+				t.Errorf("URL: %s is not expected", u)
+			}
+			if (r.TLS != nil) != tt.tls {
+				t.Errorf("TLS: %#v is not expected", r.TLS)
+			}
+			if r.TLS != nil && r.TLS.ServerName != r.Host {
+				t.Errorf("TLS.ServerName: %s is not expected", r.TLS.ServerName)
+			}
+		})
+	}
+}
+
+func TestRequestOriginALB(t *testing.T) {
+	for _, proto := range []string{"http", "https"} {
+		t.Run(proto, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Host = "alb.example.com"
+			req.Header.Set("X-Forwarded-For", "198.51.100.1, 203.0.113.9")
+			req.Header.Set("X-Forwarded-Proto", proto)
+			event, err := ridge.ToRequestALB(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, _ := json.Marshal(event)
+			r, err := ridge.NewRequest(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.RemoteAddr != "203.0.113.9:0" {
+				t.Errorf("RemoteAddr: %s is not expected", r.RemoteAddr)
+			}
+			if r.URL.Scheme != proto || r.URL.Host != "alb.example.com" {
+				t.Errorf("URL: %s is not expected", r.URL)
+			}
+			if (r.TLS != nil) != (proto == "https") {
+				t.Errorf("TLS: %#v is not expected", r.TLS)
+			}
+		})
+	}
+}
+
+func originHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, _ := net.SplitHostPort(r.RemoteAddr)
+		fmt.Fprintf(w, "%s %s %s %t", ip, r.URL.Scheme, r.Host, r.TLS != nil)
+	})
+}
+
+func TestTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []string
+		expect  func(addr string) string
+	}{
+		{
+			name:    "trusted",
+			proxies: []string{"127.0.0.1", "192.0.2.0/24"},
+			expect:  func(string) string { return "198.51.100.1 https app.example.com true" },
+		},
+		{
+			name:    "untrusted",
+			proxies: []string{"10.0.0.0/8"},
+			expect:  func(addr string) string { return "127.0.0.1 http " + addr + " false" },
+		},
+		{
+			name:   "none",
+			expect: func(addr string) string { return "127.0.0.1 http " + addr + " false" },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := ridge.New("", "/", originHandler())
+			r.TrustedProxies = tt.proxies
+			addr := startServer(t, r)
+			req, _ := http.NewRequest(http.MethodGet, "http://"+addr+"/", nil)
+			req.Header.Set("X-Forwarded-For", "203.0.113.1, 198.51.100.1, 192.0.2.1")
+			req.Header.Set("X-Forwarded-Proto", "https")
+			req.Header.Set("X-Forwarded-Host", "app.example.com")
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			b, _ := io.ReadAll(res.Body)
+			if got := string(b); got != tt.expect(addr) {
+				t.Errorf("unexpected origin: %s", got)
+			}
+		})
+	}
+}
+
+func TestTrustedProxiesInvalid(t *testing.T) {
+	r := ridge.New("127.0.0.1:0", "/", originHandler())
+	r.TrustedProxies = []string{"invalid"}
+	if err := r.Serve(context.Background()); err == nil {
+		t.Error("expected an error for invalid trusted proxies")
+	}
+}
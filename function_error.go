@@ -0,0 +1,150 @@
+package ridge
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+)
+
+// DefaultFunctionErrorStatus is a default status code of responses for failed invocations on net/http's server.
+var DefaultFunctionErrorStatus = http.StatusBadGateway
+
+// FunctionError represents a failure of the invocation marked by FailInvocation.
+type FunctionError struct {
+	Type    string `json:"errorType"`
+	Message string `json:"errorMessage"`
+}
+
+func (e *FunctionError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Type, e.Message)
+}
+
+// invocationState holds a failure of the invocation marked by handlers.
+type invocationState struct {
+	mu  sync.Mutex
+	err *FunctionError
+}
+
+type invocationStateKey struct{}
+
+func withInvocationState(ctx context.Context) (context.Context, *invocationState) {
+	s := &invocationState{}
+	return context.WithValue(ctx, invocationStateKey{}, s), s
+}
+
+func (s *invocationState) failure() *FunctionError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// FailInvocation marks the invocation of the request as failed with errorType and message.
+// On AWS Lambda runtime, the invocation returns a function error of them instead of the HTTP response,
+// to make asynchronous invocations retry, be sent to destinations, or SQS messages redriven.
+// On net/http's server, Ridge.FunctionErrorStatus is responded with the error as a JSON body, as Transport does.
+// It returns false when the request is not served by ridge.
+func FailInvocation(ctx context.Context, errorType, message string) bool {
+	s, ok := ctx.Value(invocationStateKey{}).(*invocationState)
+	if !ok {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = &FunctionError{Type: errorType, Message: message}
+	return true
+}
+
+// lambdaError converts err to an error which the Lambda runtime reports with the error type.
+func (e *FunctionError) lambdaError() error {
+	return messages.InvokeResponse_Error{Type: e.Type, Message: e.Message}
+}
+
+// withFunctionError responds failures marked by FailInvocation on net/http's server.
+// Responses written by the handler after the failure are discarded.
+// On AWS Lambda runtime, Invoke handles failures, so it passes requests through.
+func (r *Ridge) withFunctionError(next http.Handler) http.Handler {
+	status := r.FunctionErrorStatus
+	if status == 0 {
+		status = DefaultFunctionErrorStatus
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if _, ok := req.Context().Value(invocationStateKey{}).(*invocationState); ok {
+			next.ServeHTTP(w, req)
+			return
+		}
+		ctx, s := withInvocationState(req.Context())
+		fw := &functionErrorWriter{ResponseWriter: w, state: s}
+		next.ServeHTTP(fw, req.WithContext(ctx))
+		fe := s.failure()
+		if fe == nil {
+			return
+		}
+		if fw.written {
+			log.Printf("invocation failed after the response is written: %s", fe)
+			return
+		}
+		w.Header().Set(FunctionErrorHeaderName, "Unhandled")
+		writeJSON(w, status, fe)
+	})
+}
+
+// functionErrorWriter discards responses after the invocation is marked as failed.
+type functionErrorWriter struct {
+	http.ResponseWriter
+	state   *invocationState
+	written bool
+}
+
+func (w *functionErrorWriter) discard() bool {
+	return !w.written && w.state.failure() != nil
+}
+
+func (w *functionErrorWriter) WriteHeader(code int) {
+	if w.discard() {
+		return
+	}
+	w.written = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *functionErrorWriter) Write(b []byte) (int, error) {
+	if w.discard() {
+		return len(b), nil
+	}
+	w.written = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *functionErrorWriter) Flush() {
+	if w.discard() {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.written = true
+		f.Flush()
+	}
+}
+
+// Hijack hijacks the connection, e.g. to upgrade it to WebSocket.
+// A failure marked after that is not responded.
+func (w *functionErrorWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T does not implement http.Hijacker", w.ResponseWriter)
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		w.written = true
+	}
+	return conn, rw, err
+}
+
+func (w *functionErrorWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
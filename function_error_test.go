@@ -0,0 +1,153 @@
+package ridge_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/fujiwara/ridge"
+)
+
+func failHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ridge.FailInvocation(r.Context(), "ValidationError", "invalid payload") {
+			panic("the request is not served by ridge")
+		}
+		io.WriteString(w, "discarded")
+	})
+}
+
+func TestFailInvocationOnLambda(t *testing.T) {
+	r := ridge.New("", "/", failHandler())
+	event := ridge.RequestV2{Version: "2.0", RawPath: "/"}
+	event.RequestContext.HTTP.Method = http.MethodPost
+	b, _ := json.Marshal(event)
+	_, err := r.Invoke(context.Background(), b)
+	var ie messages.InvokeResponse_Error
+	if !errors.As(err, &ie) {
+		t.Fatalf("expected a function error, got %v", err)
+	}
+	if ie.Type != "ValidationError" || ie.Message != "invalid payload" {
+		t.Errorf("unexpected function error: %#v", ie)
+	}
+}
+
+func TestFailInvocationTransport(t *testing.T) {
+	r := ridge.New("", "/", failHandler())
+	res, err := transportClient(r).Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var fe ridge.FunctionError
+	if err := json.NewDecoder(res.Body).Decode(&fe); err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusBadGateway || res.Header.Get(ridge.FunctionErrorHeaderName) != "Unhandled" {
+		t.Errorf("unexpected response: %d %v", res.StatusCode, res.Header)
+	}
+	if fe.Type != "ValidationError" || fe.Message != "invalid payload" {
+		t.Errorf("unexpected function error: %#v", fe)
+	}
+}
+
+func TestFailInvocationStreaming(t *testing.T) {
+	r := ridge.New("", "/", failHandler())
+	r.StreamingResponse = true
+	res, err := transportClient(r).Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if _, err := io.ReadAll(res.Body); err == nil {
+		t.Error("expected the stream to be terminated")
+	}
+}
+
+func TestFailInvocationLocal(t *testing.T) {
+	for _, status := range []int{0, http.StatusServiceUnavailable} {
+		r := ridge.New("", "/", failHandler())
+		r.FunctionErrorStatus = status
+		addr := startServer(t, r)
+		res, err := http.Get("http://" + addr + "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		expected := status
+		if expected == 0 {
+			expected = ridge.DefaultFunctionErrorStatus
+		}
+		if res.StatusCode != expected {
+			t.Errorf("unexpected status code: %d", res.StatusCode)
+		}
+		var fe ridge.FunctionError
+		if err := json.NewDecoder(res.Body).Decode(&fe); err != nil {
+			t.Fatal(err)
+		}
+		if fe.Type != "ValidationError" || fe.Message != "invalid payload" {
+			t.Errorf("unexpected function error: %#v", fe)
+		}
+	}
+}
+
+func TestFailInvocationLocalAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	r := ridge.New("", "/", failHandler())
+	r.AccessLog = &ridge.AccessLog{Format: ridge.AccessLogFormatJSON, Writer: &buf}
+	addr := startServer(t, r)
+	res, err := http.Get("http://" + addr + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("failed to decode access log %q: %s", buf.String(), err)
+	}
+	if entry["status"] != float64(res.StatusCode) || entry["bytes"] != float64(len(b)) {
+		t.Errorf("the access log does not match the response %d %s: %s", res.StatusCode, b, buf.String())
+	}
+}
+
+func TestHijackLocal(t *testing.T) {
+	r := ridge.New("", "/", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		h, ok := w.(http.Hijacker)
+		if !ok {
+			http.Error(w, "not a hijacker", http.StatusInternalServerError)
+			return
+		}
+		conn, rw, err := h.Hijack()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close()
+		io.WriteString(rw, "HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		rw.Flush()
+	}))
+	ts := httptest.NewServer(r.Handler())
+	defer ts.Close()
+	res, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK || string(b) != "hijacked" {
+		t.Errorf("unexpected response: %d %s", res.StatusCode, b)
+	}
+}
+
+func TestFailInvocationOutsideRidge(t *testing.T) {
+	if ridge.FailInvocation(context.Background(), "Error", "message") {
+		t.Error("FailInvocation should return false outside ridge")
+	}
+}
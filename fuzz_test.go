@@ -0,0 +1,77 @@
+package ridge_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fujiwara/ridge"
+)
+
+func FuzzNewRequest(f *testing.F) {
+	files, _ := filepath.Glob("test/*.json")
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b)
+	}
+	for _, tt := range invalidEvents {
+		f.Add([]byte(tt.event))
+	}
+	f.Fuzz(func(t *testing.T, event []byte) {
+		req, err := ridge.NewRequest(json.RawMessage(event))
+		if err != nil {
+			if !errors.Is(err, ridge.ErrInvalidEvent) && !errors.Is(err, ridge.ErrUnsupportedPayloadVersion) {
+				t.Errorf("untyped error: %v", err)
+			}
+			return
+		}
+		if req.Method == "" || req.URL == nil || req.URL.Path == "" {
+			t.Errorf("invalid request: %#v", req)
+		}
+	})
+}
+
+func FuzzDecodeLogStream(f *testing.F) {
+	b, err := os.ReadFile("test/logstream.json")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(b)
+	f.Add([]byte(`{"awslogs":{"data":""}}`))
+	f.Fuzz(func(t *testing.T, event []byte) {
+		if _, err := ridge.DecodeLogStream(json.RawMessage(event)); err != nil && !errors.Is(err, ridge.ErrInvalidEvent) {
+			t.Errorf("untyped error: %v", err)
+		}
+	})
+}
+
+func FuzzResponseFor(f *testing.F) {
+	f.Add(200, "Content-Type", "text/plain", []byte("Hello"), "2.0")
+	f.Add(404, "Content-Type", "image/png", []byte{0x89, 'P', 'N', 'G'}, "1.0")
+	f.Add(500, "Content-Encoding", "gzip", []byte{}, "")
+	f.Fuzz(func(t *testing.T, code int, key, value string, body []byte, version string) {
+		w := ridge.NewResponseWriter()
+		w.Header().Set(key, value)
+		w.WriteHeader(code)
+		w.Write(body)
+		res := w.ResponseFor(version)
+		if res.StatusCode != code {
+			t.Errorf("unexpected status code: %d", res.StatusCode)
+		}
+		if _, err := json.Marshal(res); err != nil {
+			t.Errorf("failed to encode response: %s", err)
+		}
+		rw := ridge.NewResponseWriter()
+		if _, err := res.WriteTo(rw); err != nil {
+			t.Errorf("failed to write response: %s", err)
+		}
+		if rw.String() != string(body) {
+			t.Errorf("body mismatch: %q != %q", rw.String(), body)
+		}
+	})
+}
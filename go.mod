@@ -6,4 +6,7 @@ require (
 	github.com/aws/aws-lambda-go v1.48.0
 	github.com/google/go-cmp v0.6.0
 	github.com/pires/go-proxyproto v0.8.0
+	golang.org/x/net v0.34.0
 )
+
+require golang.org/x/text v0.21.0 // indirect
@@ -0,0 +1,95 @@
+package ridge
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// handler returns http.Handler that serves requests on AWS Lambda runtime and net/http's server.
+// It is built once at the first call, so Middlewares are created once on both of them.
+func (r *Ridge) handler() http.Handler {
+	r.handlerOnce.Do(func() {
+		r.builtHandler = r.newHandler()
+	})
+	return r.builtHandler
+}
+
+// newHandler builds the handler. The mounted mux is wrapped by the enabled middlewares.
+//
+// withRecover is the only layer recovering panics. It is outside Middlewares to recover panics in them as well,
+// and inside access logs and metrics to record the error page written on panics.
+// withFunctionError is inside them as well, to record the status of failed invocations on net/http's server.
+func (r *Ridge) newHandler() http.Handler {
+	h := r.withStripBasePath(r.mountMux())
+	if r.Idempotency != nil {
+		h = r.Idempotency.wrap(h, r.AsyncInvocation)
+	}
+	for i := len(r.Middlewares) - 1; i >= 0; i-- {
+		h = r.Middlewares[i](h)
+	}
+	h = r.withRecover(r.withFunctionError(h))
+	if r.Metrics != nil {
+		h = r.Metrics.wrap(h)
+	}
+	if r.AccessLog != nil {
+		h = r.AccessLog.wrap(h)
+	}
+	if r.PropagateTrace {
+		h = withTraceContext(h)
+	}
+	return r.withColdStart(r.withAsyncInvocation(h))
+}
+
+// responseRecorder is a http.ResponseWriter that records a status code and a size of the response body.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+	panicked    bool
+}
+
+func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	return &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+// reset discards the recorded response, which is replaced by another one.
+func (w *responseRecorder) reset() {
+	w.bytes = 0
+	w.wroteHeader = false
+}
+
+func (w *responseRecorder) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *responseRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wroteHeader = true
+		f.Flush()
+	}
+}
+
+func (w *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, fmt.Errorf("%T does not implement http.Hijacker", w.ResponseWriter)
+}
+
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
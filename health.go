@@ -0,0 +1,254 @@
+package ridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultReadinessCacheTTL is a default duration to cache results of readiness checks.
+var DefaultReadinessCacheTTL = time.Second
+
+// DefaultReadinessCheckTimeout is a default timeout of each readiness check.
+var DefaultReadinessCheckTimeout = 3 * time.Second
+
+// Runtime modes reported by the info endpoint.
+const (
+	RuntimeModeLambdaHandler   = "lambda_handler"
+	RuntimeModeLambdaExtension = "lambda_extension"
+	RuntimeModeLocalServer     = "local_server"
+)
+
+// ReadinessCheck represents a check of dependencies for the readiness endpoint.
+type ReadinessCheck struct {
+	Name string
+	// Check returns an error when the dependency is not ready.
+	// The context is cancelled after Timeout, and the check is regarded as failed even if it does not return.
+	Check func(context.Context) error
+	// Timeout is a timeout of the check. default is DefaultReadinessCheckTimeout.
+	Timeout time.Duration
+}
+
+type healthStatus struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+type readinessCache struct {
+	mu        sync.Mutex
+	status    healthStatus
+	ok        bool
+	expiresAt time.Time
+	running   chan struct{} // closed when the running checks complete
+}
+
+// RuntimeMode returns how the process runs. RuntimeModeLambdaHandler, RuntimeModeLambdaExtension or RuntimeModeLocalServer.
+func RuntimeMode() string {
+	switch {
+	case AsLambdaHandler():
+		return RuntimeModeLambdaHandler
+	case AsLambdaExtension():
+		return RuntimeModeLambdaExtension
+	default:
+		return RuntimeModeLocalServer
+	}
+}
+
+// validateHealthPaths returns an error for paths of the endpoints which cannot be mounted.
+// They must start with "/", must not be "/" which is used by the mounts, and must be unique.
+func (r *Ridge) validateHealthPaths() error {
+	seen := make(map[string]string, 3)
+	for _, p := range []struct{ name, path string }{
+		{"HealthPath", r.HealthPath},
+		{"ReadinessPath", r.ReadinessPath},
+		{"InfoPath", r.InfoPath},
+	} {
+		if p.path == "" {
+			continue
+		}
+		if !strings.HasPrefix(p.path, "/") || p.path == "/" {
+			return fmt.Errorf("%s must start with \"/\" and must not be \"/\": %q", p.name, p.path)
+		}
+		if name, ok := seen[p.path]; ok {
+			return fmt.Errorf("%s and %s have the same path: %q", name, p.name, p.path)
+		}
+		seen[p.path] = p.name
+	}
+	return nil
+}
+
+// mountHealth mounts the enabled health, readiness and info endpoints to m.
+func (r *Ridge) mountHealth(m *http.ServeMux) {
+	if r.HealthPath != "" {
+		m.Handle(r.HealthPath, http.HandlerFunc(r.serveHealth))
+	}
+	if r.ReadinessPath != "" {
+		m.Handle(r.ReadinessPath, http.HandlerFunc(r.serveReadiness))
+	}
+	if r.InfoPath != "" {
+		m.Handle(r.InfoPath, http.HandlerFunc(r.serveInfo))
+	}
+}
+
+// serveHealth responds 200 OK while the process is alive.
+func (r *Ridge) serveHealth(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusOK, healthStatus{Status: "ok"})
+}
+
+// serveReadiness responds 200 OK when all checks pass,
+// or 503 Service Unavailable while draining or when any check fails.
+func (r *Ridge) serveReadiness(w http.ResponseWriter, req *http.Request) {
+	if r.isDraining() {
+		writeJSON(w, http.StatusServiceUnavailable, healthStatus{Status: "draining"})
+		return
+	}
+	status, ok := r.checkReadiness(req.Context())
+	code := http.StatusOK
+	if !ok {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, status)
+}
+
+// checkReadiness returns the cached results of the readiness checks, or runs them.
+// Concurrent callers share one run of the checks, and the lock is not held while the checks run.
+func (r *Ridge) checkReadiness(ctx context.Context) (healthStatus, bool) {
+	c := &r.readiness
+	c.mu.Lock()
+	if time.Now().Before(c.expiresAt) {
+		defer c.mu.Unlock()
+		return c.status, c.ok
+	}
+	running := c.running
+	if running == nil {
+		running = make(chan struct{})
+		c.running = running
+		go func() {
+			status, ok := r.runReadinessChecks()
+			ttl := r.ReadinessCacheTTL
+			if ttl == 0 {
+				ttl = DefaultReadinessCacheTTL
+			}
+			c.mu.Lock()
+			c.status, c.ok, c.expiresAt = status, ok, time.Now().Add(ttl)
+			c.running = nil
+			c.mu.Unlock()
+			close(running)
+		}()
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-running:
+	case <-ctx.Done():
+		return healthStatus{Status: "unavailable"}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status, c.ok
+}
+
+// runReadinessChecks runs the readiness checks concurrently, each within its timeout.
+// The checks run independently of requests, not to be cancelled by a client going away.
+func (r *Ridge) runReadinessChecks() (healthStatus, bool) {
+	status := healthStatus{Status: "ok"}
+	if len(r.ReadinessChecks) == 0 {
+		return status, true
+	}
+	results := make([]error, len(r.ReadinessChecks))
+	var wg sync.WaitGroup
+	for i, check := range r.ReadinessChecks {
+		wg.Add(1)
+		go func(i int, check ReadinessCheck) {
+			defer wg.Done()
+			results[i] = runReadinessCheck(check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	ok := true
+	status.Checks = make(map[string]string, len(r.ReadinessChecks))
+	for i, check := range r.ReadinessChecks {
+		if err := results[i]; err != nil {
+			status.Checks[check.Name] = err.Error()
+			ok = false
+		} else {
+			status.Checks[check.Name] = "ok"
+		}
+	}
+	if !ok {
+		status.Status = "unavailable"
+	}
+	return status, ok
+}
+
+// runReadinessCheck runs the check, and gives up waiting for it after the timeout.
+func runReadinessCheck(check ReadinessCheck) error {
+	timeout := check.Timeout
+	if timeout == 0 {
+		timeout = DefaultReadinessCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- check.Check(ctx) }()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+}
+
+type infoDocument struct {
+	Path              string `json:"path,omitempty"`
+	Version           string `json:"version,omitempty"`
+	GoVersion         string `json:"go_version"`
+	VCSRevision       string `json:"vcs_revision,omitempty"`
+	VCSTime           string `json:"vcs_time,omitempty"`
+	VCSModified       bool   `json:"vcs_modified,omitempty"`
+	RuntimeMode       string `json:"runtime_mode"`
+	PayloadVersion    string `json:"payload_version,omitempty"`
+	StreamingResponse bool   `json:"streaming_response"`
+}
+
+// serveInfo responds a JSON document of the build and the runtime.
+func (r *Ridge) serveInfo(w http.ResponseWriter, req *http.Request) {
+	doc := infoDocument{
+		GoVersion:         runtime.Version(),
+		RuntimeMode:       RuntimeMode(),
+		PayloadVersion:    req.Header.Get(PayloadVersionHeaderName),
+		StreamingResponse: r.StreamingResponse,
+	}
+	if doc.PayloadVersion == "" {
+		doc.PayloadVersion = PayloadVersion
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		doc.Path = bi.Main.Path
+		doc.Version = bi.Main.Version
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				doc.VCSRevision = s.Value
+			case "vcs.time":
+				doc.VCSTime = s.Value
+			case "vcs.modified":
+				doc.VCSModified = s.Value == "true"
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, doc)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,169 @@
+package ridge_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fujiwara/ridge"
+)
+
+func serveJSON(t *testing.T, h http.Handler, path string, v interface{}) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("unexpected content type: %s", ct)
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatal(err)
+	}
+	return w.Code
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "user handler", http.StatusTeapot)
+	})
+	r := ridge.New("", "/api", mux)
+	r.HealthPath = "/healthz"
+	r.ReadinessPath = "/readyz"
+	r.InfoPath = "/info"
+	h := r.Handler()
+
+	var status struct {
+		Status string `json:"status"`
+	}
+	if code := serveJSON(t, h, "/healthz", &status); code != http.StatusOK || status.Status != "ok" {
+		t.Errorf("unexpected health: %d %v", code, status)
+	}
+	if code := serveJSON(t, h, "/readyz", &status); code != http.StatusOK || status.Status != "ok" {
+		t.Errorf("unexpected readiness: %d %v", code, status)
+	}
+
+	var info map[string]interface{}
+	if code := serveJSON(t, h, "/info", &info); code != http.StatusOK {
+		t.Errorf("unexpected info status: %d", code)
+	}
+	if info["go_version"] != runtime.Version() || info["runtime_mode"] != ridge.RuntimeModeLocalServer || info["streaming_response"] != false {
+		t.Errorf("unexpected info: %v", info)
+	}
+
+	// the user prefix is not affected
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/healthz", nil))
+	if w.Code != http.StatusTeapot {
+		t.Errorf("unexpected status under the prefix: %d", w.Code)
+	}
+}
+
+func TestHealthPathsInvalid(t *testing.T) {
+	tests := []struct {
+		health, readiness, info string
+	}{
+		{"/", "", ""},
+		{"", "healthz", ""},
+		{"/healthz", "", "/healthz"},
+	}
+	for _, tt := range tests {
+		r := ridge.New("127.0.0.1:0", "/", http.NotFoundHandler())
+		r.HealthPath, r.ReadinessPath, r.InfoPath = tt.health, tt.readiness, tt.info
+		if err := r.Serve(context.Background()); err == nil {
+			t.Errorf("expected error for %#v", tt)
+		}
+	}
+}
+
+func TestReadinessChecks(t *testing.T) {
+	var calls int
+	var dbErr error
+	r := ridge.New("", "/", http.NotFoundHandler())
+	r.ReadinessPath = "/readyz"
+	r.ReadinessCacheTTL = 100 * time.Millisecond
+	r.ReadinessChecks = []ridge.ReadinessCheck{
+		{Name: "db", Check: func(ctx context.Context) error {
+			calls++
+			return dbErr
+		}},
+	}
+
+	var status struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}
+	dbErr = errors.New("connection refused")
+	if code := serveJSON(t, r.Handler(), "/readyz", &status); code != http.StatusServiceUnavailable {
+		t.Errorf("unexpected readiness status: %d", code)
+	}
+	if status.Status != "unavailable" || status.Checks["db"] != "connection refused" {
+		t.Errorf("unexpected readiness: %v", status)
+	}
+
+	// the result is cached
+	dbErr = nil
+	if code := serveJSON(t, r.Handler(), "/readyz", &status); code != http.StatusServiceUnavailable {
+		t.Errorf("unexpected readiness status: %d", code)
+	}
+	if calls != 1 {
+		t.Errorf("checks must be cached: called %d times", calls)
+	}
+
+	// the cache is expired
+	time.Sleep(150 * time.Millisecond)
+	if code := serveJSON(t, r.Handler(), "/readyz", &status); code != http.StatusOK {
+		t.Errorf("unexpected readiness status: %d", code)
+	}
+	if status.Checks["db"] != "ok" {
+		t.Errorf("unexpected readiness: %v", status)
+	}
+}
+
+func TestReadinessCheckTimeout(t *testing.T) {
+	var calls int32
+	block := make(chan struct{})
+	defer close(block)
+	r := ridge.New("", "/", http.NotFoundHandler())
+	r.ReadinessPath = "/readyz"
+	r.ReadinessChecks = []ridge.ReadinessCheck{
+		{Name: "db", Check: func(ctx context.Context) error { return nil }},
+		{Name: "hang", Timeout: 100 * time.Millisecond, Check: func(ctx context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			<-block // ignores ctx
+			return nil
+		}},
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			var status struct {
+				Status string            `json:"status"`
+				Checks map[string]string `json:"checks"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &status)
+			if w.Code != http.StatusServiceUnavailable || status.Checks["db"] != "ok" || status.Checks["hang"] != "timed out after 100ms" {
+				t.Errorf("unexpected readiness: %d %v", w.Code, status)
+			}
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("readiness probes are blocked by the hanging check: %s", elapsed)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("concurrent probes must share one run of checks: called %d times", n)
+	}
+}
@@ -0,0 +1,338 @@
+package ridge
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultIdempotencyTTL is a default duration to keep responses for duplicate invocations.
+var DefaultIdempotencyTTL = time.Hour
+
+// Idempotency represents a configuration of the idempotency middleware.
+// When Ridge.Idempotency is set, successful responses of asynchronous invocations are stored by a key of the request,
+// and duplicate requests with the same key are responded by the stored response without calling handlers.
+//
+// Duplicate requests in the same execution environment wait for the in-flight request with the same key.
+// Duplicates delivered to other execution environments are caught only after the first request finishes
+// and its response is stored.
+type Idempotency struct {
+	// Store is a store of responses. default is an in-memory store of the execution environment.
+	Store IdempotencyStore
+	// Key returns a key of the request. A request with an empty key is not handled by the middleware.
+	// default is IdempotencyKeyByRequestID.
+	Key func(*http.Request) (string, error)
+	// TTL is a duration to keep responses. default is DefaultIdempotencyTTL.
+	TTL time.Duration
+	// SyncToo applies the middleware to synchronous invocations too.
+	// By default, it applies only to asynchronous invocations (Ridge.AsyncInvocation).
+	SyncToo bool
+
+	once     sync.Once
+	memory   *MemoryIdempotencyStore
+	mu       sync.Mutex
+	inflight map[string]chan struct{}
+}
+
+// IdempotencyRecord represents a stored response.
+type IdempotencyRecord struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	ExpiresAt  time.Time   `json:"expires_at"`
+}
+
+// IdempotencyStore is a store of responses for the idempotency middleware.
+type IdempotencyStore interface {
+	// Get returns the record of the key. It returns nil without an error when the record is not found or expired.
+	Get(ctx context.Context, key string) (*IdempotencyRecord, error)
+	// Put stores the record of the key.
+	Put(ctx context.Context, key string, rec *IdempotencyRecord) error
+}
+
+// IdempotencyKeyByRequestID returns the AWS request ID as a key.
+// Retries of asynchronous invocations have the same request ID.
+// It returns an empty key when the request is not an invocation on AWS Lambda runtime.
+func IdempotencyKeyByRequestID(req *http.Request) (string, error) {
+	return awsRequestID(req), nil
+}
+
+// IdempotencyKeyByPayloadHash returns a SHA-256 hash of the method, the request URI and the body as a key.
+// It detects duplicate events delivered by different invocations, e.g. at-least-once delivery of event sources.
+// It returns an empty key for synchronous invocations, because the hash does not include callers
+// and a response for a caller must not be returned to others.
+func IdempotencyKeyByPayloadHash(req *http.Request) (string, error) {
+	if _, ok := AsyncInvocationFromContext(req.Context()); !ok {
+		return "", nil
+	}
+	var body []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return "", err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(b))
+		body = b
+	}
+	h := sha256.New()
+	io.WriteString(h, req.Method+" "+req.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (i *Idempotency) store() IdempotencyStore {
+	if i.Store != nil {
+		return i.Store
+	}
+	i.once.Do(func() {
+		i.memory = NewMemoryIdempotencyStore()
+	})
+	return i.memory
+}
+
+// acquire waits for the in-flight request with the same key in the execution environment,
+// and returns a function to release the key.
+func (i *Idempotency) acquire(ctx context.Context, key string) (func(), error) {
+	for {
+		i.mu.Lock()
+		ch, ok := i.inflight[key]
+		if !ok {
+			if i.inflight == nil {
+				i.inflight = make(map[string]chan struct{})
+			}
+			ch = make(chan struct{})
+			i.inflight[key] = ch
+			i.mu.Unlock()
+			return func() {
+				i.mu.Lock()
+				delete(i.inflight, key)
+				i.mu.Unlock()
+				close(ch)
+			}, nil
+		}
+		i.mu.Unlock()
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (i *Idempotency) wrap(next http.Handler, async bool) http.Handler {
+	keyFunc := i.Key
+	if keyFunc == nil {
+		keyFunc = IdempotencyKeyByRequestID
+	}
+	ttl := i.TTL
+	if ttl == 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !async && !i.SyncToo {
+			next.ServeHTTP(w, req)
+			return
+		}
+		key, err := keyFunc(req)
+		if err != nil {
+			log.Println("failed to get an idempotency key:", err)
+		}
+		if key == "" {
+			next.ServeHTTP(w, req)
+			return
+		}
+		ctx := req.Context()
+		release, err := i.acquire(ctx, key)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		defer release()
+		store := i.store()
+		rec, err := store.Get(ctx, key)
+		if err != nil {
+			log.Println("failed to get an idempotency record:", err)
+		} else if rec != nil {
+			for k, v := range rec.Header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(rec.StatusCode)
+			w.Write(rec.Body)
+			return
+		}
+
+		before := w.Header().Clone()
+		cw := &capturingResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(cw, req)
+		if cw.status >= 500 {
+			return
+		}
+		if s, ok := ctx.Value(invocationStateKey{}).(*invocationState); ok && s.failure() != nil {
+			return
+		}
+		rec = &IdempotencyRecord{
+			StatusCode: cw.status,
+			Header:     changedHeader(before, w.Header()),
+			Body:       cw.body.Bytes(),
+			ExpiresAt:  time.Now().Add(ttl),
+		}
+		if err := store.Put(ctx, key, rec); err != nil {
+			log.Println("failed to put an idempotency record:", err)
+		}
+	})
+}
+
+// changedHeader returns headers in after which are added or modified from before.
+// Headers set by outer middlewares (e.g. ColdStartHeader) are not stored.
+func changedHeader(before, after http.Header) http.Header {
+	h := make(http.Header)
+	for key, values := range after {
+		if strings.Join(before[key], "\n") != strings.Join(values, "\n") {
+			h[key] = values
+		}
+	}
+	return h
+}
+
+// capturingResponseWriter records a response to store it.
+type capturingResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *capturingResponseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *capturingResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *capturingResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wroteHeader = true
+		f.Flush()
+	}
+}
+
+func (w *capturingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// MemoryIdempotencyStore is an in-memory IdempotencyStore.
+// Records are kept only in the execution environment.
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*IdempotencyRecord
+}
+
+// NewMemoryIdempotencyStore creates MemoryIdempotencyStore.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{records: make(map[string]*IdempotencyRecord)}
+}
+
+func (s *MemoryIdempotencyStore) Get(_ context.Context, key string) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.records[key]
+	if !ok {
+		return nil, nil
+	}
+	if time.Now().After(rec.ExpiresAt) {
+		delete(s.records, key)
+		return nil, nil
+	}
+	return rec, nil
+}
+
+func (s *MemoryIdempotencyStore) Put(_ context.Context, key string, rec *IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for k, r := range s.records {
+		if now.After(r.ExpiresAt) {
+			delete(s.records, k)
+		}
+	}
+	s.records[key] = rec
+	return nil
+}
+
+// FileIdempotencyStore is an IdempotencyStore which stores records as JSON files in a directory.
+// It is useful for tests and local development.
+type FileIdempotencyStore struct {
+	Dir string
+}
+
+// NewFileIdempotencyStore creates FileIdempotencyStore in dir.
+func NewFileIdempotencyStore(dir string) *FileIdempotencyStore {
+	return &FileIdempotencyStore{Dir: dir}
+}
+
+func (s *FileIdempotencyStore) path(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return filepath.Join(s.Dir, hex.EncodeToString(h[:])+".json")
+}
+
+func (s *FileIdempotencyStore) Get(_ context.Context, key string) (*IdempotencyRecord, error) {
+	b, err := os.ReadFile(s.path(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var rec IdempotencyRecord
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return nil, err
+	}
+	if time.Now().After(rec.ExpiresAt) {
+		os.Remove(s.path(key))
+		return nil, nil
+	}
+	return &rec, nil
+}
+
+func (s *FileIdempotencyStore) Put(_ context.Context, key string, rec *IdempotencyRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return err
+	}
+	// write to a temporary file and rename it, not to read a partially written record.
+	f, err := os.CreateTemp(s.Dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.path(key))
+}
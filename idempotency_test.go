@@ -0,0 +1,233 @@
+package ridge_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/fujiwara/ridge"
+)
+
+func invokeAsync(t *testing.T, r *ridge.Ridge, requestID string) (ridge.Response, error) {
+	t.Helper()
+	event := ridge.RequestV2{Version: "2.0", RawPath: "/"}
+	event.RequestContext.HTTP.Method = http.MethodPost
+	b, _ := json.Marshal(event)
+	ctx := lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{AwsRequestID: requestID})
+	res, err := r.Invoke(ctx, b)
+	if err != nil {
+		return ridge.Response{}, err
+	}
+	return res.(ridge.Response), nil
+}
+
+func asyncHandler(status int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a, ok := ridge.AsyncInvocationFromContext(r.Context())
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "%t %s %d %t", ok, a.RequestID, a.Attempt, a.IsRetry())
+	})
+}
+
+func TestAsyncInvocation(t *testing.T) {
+	r := ridge.New("", "/", asyncHandler(http.StatusOK))
+	res, err := invokeAsync(t, r, "req-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Body != "false  0 false" {
+		t.Errorf("unexpected body for a sync invocation: %s", res.Body)
+	}
+	r.AsyncInvocation = true
+	for i, expected := range []string{"true req-1 1 false", "true req-1 2 true"} {
+		res, err := invokeAsync(t, r, "req-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Body != expected {
+			t.Errorf("unexpected body of attempt %d: %s", i+1, res.Body)
+		}
+	}
+}
+
+func TestAsyncInvocationFailsOn5xx(t *testing.T) {
+	r := ridge.New("", "/", asyncHandler(http.StatusServiceUnavailable))
+	r.AsyncInvocation = true
+	_, err := invokeAsync(t, r, "req-1")
+	var ie messages.InvokeResponse_Error
+	if !errors.As(err, &ie) || ie.Type != "HTTPError" {
+		t.Fatalf("expected a function error, got %v", err)
+	}
+}
+
+func countingHandler(calls *int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(calls, 1)
+		w.Header().Set("X-Calls", fmt.Sprint(n))
+		if r.URL.Path == "/fail" {
+			http.Error(w, "failed", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "call %d", n)
+	})
+}
+
+func TestIdempotency(t *testing.T) {
+	stores := map[string]ridge.IdempotencyStore{
+		"default": nil,
+		"memory":  ridge.NewMemoryIdempotencyStore(),
+		"file":    ridge.NewFileIdempotencyStore(t.TempDir()),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			var calls int32
+			r := ridge.New("", "/", countingHandler(&calls))
+			r.AsyncInvocation = true
+			r.Idempotency = &ridge.Idempotency{Store: store}
+			for i := 0; i < 2; i++ {
+				res, err := invokeAsync(t, r, "req-1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if res.Body != "call 1" || res.Headers["X-Calls"] != "1" {
+					t.Errorf("unexpected response: %#v", res)
+				}
+			}
+			res, err := invokeAsync(t, r, "req-2")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Body != "call 2" {
+				t.Errorf("unexpected response for another request ID: %s", res.Body)
+			}
+		})
+	}
+}
+
+func TestIdempotencyNotStoreErrors(t *testing.T) {
+	var calls int32
+	r := ridge.New("", "/", countingHandler(&calls))
+	r.AsyncInvocation = true
+	r.Idempotency = &ridge.Idempotency{}
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/fail", nil)
+		ctx := lambdacontext.NewContext(req.Context(), &lambdacontext.LambdaContext{AwsRequestID: "req-1"})
+		r.Handler().ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+	}
+	if calls != 2 {
+		t.Errorf("error responses must not be stored: %d calls", calls)
+	}
+}
+
+func TestIdempotencyWithoutLambdaContext(t *testing.T) {
+	var calls int32
+	r := ridge.New("", "/", countingHandler(&calls))
+	r.AsyncInvocation = true
+	r.Idempotency = &ridge.Idempotency{}
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Lambda-Runtime-Aws-Request-Id", "req-1")
+		r.Handler().ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if calls != 2 {
+		t.Errorf("requests without a Lambda context must not be handled: %d calls", calls)
+	}
+}
+
+func TestIdempotencyInFlight(t *testing.T) {
+	var calls int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	r := ridge.New("", "/", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+			<-release
+		}
+		fmt.Fprint(w, "done")
+	}))
+	r.AsyncInvocation = true
+	r.Idempotency = &ridge.Idempotency{}
+	errCh := make(chan error, 2)
+	invoke := func() {
+		_, err := invokeAsync(t, r, "req-1")
+		errCh <- err
+	}
+	go invoke()
+	<-started
+	go invoke()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-errCh; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("a duplicate request must wait for the in-flight one: %d calls", calls)
+	}
+}
+
+func TestIdempotencyKeyByPayloadHash(t *testing.T) {
+	var calls int32
+	r := ridge.New("", "/", countingHandler(&calls))
+	r.Idempotency = &ridge.Idempotency{Key: ridge.IdempotencyKeyByPayloadHash, TTL: time.Minute, SyncToo: true}
+	serve := func() {
+		for _, body := range []string{"a", "a", "b"} {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			r.Handler().ServeHTTP(httptest.NewRecorder(), req)
+		}
+	}
+	serve()
+	if calls != 3 {
+		t.Errorf("sync invocations must not be keyed by the payload: %d calls", calls)
+	}
+	r.AsyncInvocation = true
+	serve()
+	if calls != 5 {
+		t.Errorf("unexpected calls: %d", calls)
+	}
+}
+
+func TestIdempotencySyncToo(t *testing.T) {
+	var calls int32
+	r := ridge.New("", "/", countingHandler(&calls))
+	r.Idempotency = &ridge.Idempotency{}
+	for i := 0; i < 2; i++ {
+		if _, err := invokeAsync(t, r, "req-1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("sync invocations must not be handled by default: %d calls", calls)
+	}
+	r.Idempotency.SyncToo = true
+	for i := 0; i < 2; i++ {
+		if _, err := invokeAsync(t, r, "req-2"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("sync invocations must be handled with SyncToo: %d calls", calls)
+	}
+}
+
+func TestFileIdempotencyStoreExpires(t *testing.T) {
+	ctx := context.Background()
+	s := ridge.NewFileIdempotencyStore(t.TempDir())
+	if err := s.Put(ctx, "key", &ridge.IdempotencyRecord{StatusCode: 200, ExpiresAt: time.Now().Add(-time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := s.Get(ctx, "key")
+	if err != nil || rec != nil {
+		t.Errorf("expired record is returned: %v %v", rec, err)
+	}
+}
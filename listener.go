@@ -0,0 +1,95 @@
+package ridge
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// UnixAddressPrefix is a prefix of Address to listen on a Unix domain socket. e.g. "unix:/var/run/app.sock"
+	UnixAddressPrefix = "unix:"
+	// SystemdAddressPrefix is a prefix of Address to listen on a socket passed by systemd socket activation.
+	// "systemd:" uses the first socket, and "systemd:<name>" uses the socket named by FileDescriptorName=.
+	SystemdAddressPrefix = "systemd:"
+)
+
+// listenFdsStart is the first file descriptor passed by systemd. (SD_LISTEN_FDS_START)
+var listenFdsStart = 3
+
+func (r *Ridge) baseListener() (net.Listener, error) {
+	switch {
+	case r.Listener != nil:
+		return r.Listener, nil
+	case strings.HasPrefix(r.Address, UnixAddressPrefix):
+		return listenUnix(strings.TrimPrefix(r.Address, UnixAddressPrefix), r.UnixSocketMode)
+	case strings.HasPrefix(r.Address, SystemdAddressPrefix):
+		return listenSystemd(strings.TrimPrefix(r.Address, SystemdAddressPrefix))
+	default:
+		return net.Listen("tcp", r.Address)
+	}
+}
+
+// listenUnix listens on a Unix domain socket.
+// A stale socket file left by a crashed process is removed, and the socket file is removed on close.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		conn, err := net.Dial("unix", path)
+		if err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use by another process", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// listenSystemd returns a listener passed by systemd socket activation.
+// https://www.freedesktop.org/software/systemd/man/sd_listen_fds.html
+func listenSystemd(name string) (net.Listener, error) {
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return nil, errors.New("no sockets are passed by systemd: LISTEN_PID does not match")
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, errors.New("no sockets are passed by systemd: LISTEN_FDS is invalid")
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	for i := 0; i < n; i++ {
+		if name != "" && (i >= len(names) || names[i] != name) {
+			continue
+		}
+		fd := listenFdsStart + i
+		f := os.NewFile(uintptr(fd), fmt.Sprintf("LISTEN_FD_%d", fd))
+		// FileListener duplicates the descriptor with close-on-exec flag.
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		// sockets are not passed to child processes.
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+		return l, nil
+	}
+	return nil, fmt.Errorf("no sockets named %q are passed by systemd", name)
+}
@@ -0,0 +1,113 @@
+package ridge_test
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/fujiwara/ridge"
+)
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ridge.sock")
+	r := ridge.New(ridge.UnixAddressPrefix+path, "/", http.NotFoundHandler())
+	r.UnixSocketMode = 0600
+	l, err := r.Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("unexpected mode: %s", fi.Mode())
+	}
+
+	// the socket is in use
+	if _, err := r.Listen(); err == nil {
+		t.Error("expected error for the socket in use")
+	}
+
+	// a stale socket is removed
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatal("the stale socket must remain", err)
+	}
+	l, err = r.Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("the socket must be removed on close", err)
+	}
+}
+
+func TestListenUnixNotSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ridge.sock")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := ridge.New(ridge.UnixAddressPrefix+path, "/", http.NotFoundHandler())
+	if _, err := r.Listen(); err == nil {
+		t.Error("expected error for a regular file")
+	}
+}
+
+func TestListenUserListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	r := ridge.New("", "/", http.NotFoundHandler())
+	r.Listener = l
+	got, err := r.Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != l {
+		t.Error("the user-provided listener must be used")
+	}
+}
+
+func TestListenSystemd(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	f, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	defer ridge.SetListenFdsStart(int(f.Fd()) - 1)()
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "2")
+	t.Setenv("LISTEN_FDNAMES", "other:http")
+
+	r := ridge.New(ridge.SystemdAddressPrefix+"http", "/", http.NotFoundHandler())
+	sl, err := r.Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sl.Close()
+	if sl.Addr().String() != l.Addr().String() {
+		t.Errorf("unexpected address: %s", sl.Addr())
+	}
+	if os.Getenv("LISTEN_FDS") != "" {
+		t.Error("LISTEN_FDS must be unset")
+	}
+
+	// environment variables are consumed
+	if _, err := r.Listen(); err == nil {
+		t.Error("expected error without LISTEN_FDS")
+	}
+}
@@ -33,17 +33,17 @@ func DecodeLogStream(event json.RawMessage) (*LogStream, error) {
 	msg := Message{}
 	err := json.Unmarshal(event, &msg)
 	if err != nil {
-		return nil, fmt.Errorf("could not decode event: %w", err)
+		return nil, invalidEvent("awslogs", fmt.Errorf("could not decode event: %w", err))
 	}
 	gz, err := gzip.NewReader(bytes.NewReader(msg.Awslogs.Data))
 	if err != nil {
-		return nil, fmt.Errorf("cloud not create gzip reader: %w", err)
+		return nil, invalidEvent("awslogs.data", fmt.Errorf("could not create gzip reader: %w", err))
 	}
 	dec := json.NewDecoder(gz)
 	ls := LogStream{}
 	err = dec.Decode(&ls)
 	if err != nil {
-		return nil, fmt.Errorf("cloud not decode log stream: %w", err)
+		return nil, invalidEvent("awslogs.data", fmt.Errorf("could not decode log stream: %w", err))
 	}
 	return &ls, nil
 }
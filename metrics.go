@@ -0,0 +1,205 @@
+package ridge
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+)
+
+// DefaultMetricsNamespace is a default namespace of metrics.
+var DefaultMetricsNamespace = "ridge"
+
+// Metrics represents a configuration of request metrics.
+// When Ridge.Metrics is set, ridge emits a record per request in CloudWatch Embedded Metric Format (EMF).
+// https://docs.aws.amazon.com/AmazonCloudWatch/latest/monitoring/CloudWatch_Embedded_Metric_Format_Specification.html
+type Metrics struct {
+	// Namespace is a namespace of metrics. default is DefaultMetricsNamespace.
+	Namespace string
+	// Dimensions is a list of dimensions of metrics.
+	Dimensions []MetricDimension
+	// Writer is a destination of records. default is os.Stdout.
+	// On AWS Lambda, CloudWatch Logs extracts metrics from records written to stdout.
+	Writer io.Writer
+	// Local enables to emit records when not running on AWS Lambda runtime, to validate records offline.
+	Local bool
+
+	mu sync.Mutex
+}
+
+// MetricDimension represents a dimension of metrics.
+// All dimensions are emitted in every record, and an empty value is emitted as "-",
+// not to split metrics into different dimension sets.
+type MetricDimension struct {
+	Name string
+	// Value returns a value of the dimension for the request, called after the handler returns.
+	// It does not see values set by the inner handlers (e.g. a route pattern matched by a router) to the request.
+	// Use SetMetricDimension in handlers for them.
+	Value func(*http.Request) string
+}
+
+// emptyDimensionValue is emitted for a dimension without any value.
+const emptyDimensionValue = "-"
+
+type metricDimensionsKey struct{}
+
+// metricDimensions holds values of dimensions set by SetMetricDimension.
+type metricDimensions struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+// SetMetricDimension sets a value of the dimension named name for the request of ctx.
+// It is called by handlers to set values known only inside them, e.g. a route pattern.
+// The value takes precedence over MetricDimension.Value. It does nothing when metrics are not enabled.
+func SetMetricDimension(ctx context.Context, name, value string) {
+	d, ok := ctx.Value(metricDimensionsKey{}).(*metricDimensions)
+	if !ok {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.values[name] = value
+}
+
+func (d *metricDimensions) get(name string) (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	v, ok := d.values[name]
+	return v, ok
+}
+
+// Metric names emitted by ridge.
+const (
+	MetricLatency      = "Latency"
+	MetricResponseSize = "ResponseSize"
+	MetricColdStart    = "ColdStart"
+	MetricInitDuration = "InitDuration"
+	Metric2xx          = "2xx"
+	Metric3xx          = "3xx"
+	Metric4xx          = "4xx"
+	Metric5xx          = "5xx"
+	MetricPanic        = "Panic"
+)
+
+type emfMetric struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit"`
+}
+
+type emfDirective struct {
+	Namespace  string      `json:"Namespace"`
+	Dimensions [][]string  `json:"Dimensions"`
+	Metrics    []emfMetric `json:"Metrics"`
+}
+
+type emfMetadata struct {
+	Timestamp         int64          `json:"Timestamp"`
+	CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+}
+
+var emfMetrics = []emfMetric{
+	{Name: MetricLatency, Unit: "Milliseconds"},
+	{Name: MetricResponseSize, Unit: "Bytes"},
+	{Name: MetricColdStart, Unit: "Count"},
+	{Name: Metric2xx, Unit: "Count"},
+	{Name: Metric3xx, Unit: "Count"},
+	{Name: Metric4xx, Unit: "Count"},
+	{Name: Metric5xx, Unit: "Count"},
+	{Name: MetricPanic, Unit: "Count"},
+}
+
+func (m *Metrics) wrap(next http.Handler) http.Handler {
+	if !m.Local && !OnLambdaRuntime() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		rec := newResponseRecorder(w)
+		dims := &metricDimensions{values: make(map[string]string, len(m.Dimensions))}
+		req = req.WithContext(context.WithValue(req.Context(), metricDimensionsKey{}, dims))
+		next.ServeHTTP(rec, req)
+		if err := m.write(m.record(req, rec, start, dims)); err != nil {
+			log.Println("failed to write metrics:", err)
+		}
+	})
+}
+
+func (m *Metrics) record(req *http.Request, rec *responseRecorder, start time.Time, set *metricDimensions) map[string]interface{} {
+	ns := m.Namespace
+	if ns == "" {
+		ns = DefaultMetricsNamespace
+	}
+	r := make(map[string]interface{}, len(emfMetrics)+len(m.Dimensions)+4)
+	dims := make([]string, 0, len(m.Dimensions))
+	for _, d := range m.Dimensions {
+		v, ok := set.get(d.Name)
+		if !ok && d.Value != nil {
+			v = d.Value(req)
+		}
+		if v == "" {
+			v = emptyDimensionValue
+		}
+		dims = append(dims, d.Name)
+		r[d.Name] = v
+	}
+	sort.Strings(dims)
+	metrics := emfMetrics
+	if d := InitDuration(req.Context()); d > 0 {
+		metrics = append(metrics[:len(metrics):len(metrics)], emfMetric{Name: MetricInitDuration, Unit: "Milliseconds"})
+		r[MetricInitDuration] = float64(d.Microseconds()) / 1000
+	}
+	r["_aws"] = emfMetadata{
+		Timestamp: start.UnixNano() / int64(time.Millisecond),
+		CloudWatchMetrics: []emfDirective{
+			{Namespace: ns, Dimensions: [][]string{dims}, Metrics: metrics},
+		},
+	}
+	r[MetricLatency] = float64(time.Since(start).Microseconds()) / 1000
+	r[MetricResponseSize] = rec.bytes
+	r[MetricColdStart] = boolCount(IsColdStart(req.Context()))
+	r[Metric2xx] = boolCount(rec.status/100 == 2)
+	r[Metric3xx] = boolCount(rec.status/100 == 3)
+	r[Metric4xx] = boolCount(rec.status/100 == 4)
+	r[Metric5xx] = boolCount(rec.status/100 == 5)
+	r[MetricPanic] = boolCount(rec.panicked)
+
+	// properties are not metrics but searchable in CloudWatch Logs Insights.
+	r["StatusCode"] = rec.status
+	if v := req.Header.Get(PayloadVersionHeaderName); v != "" {
+		r["PayloadVersion"] = v
+	}
+	if lc, ok := lambdacontext.FromContext(req.Context()); ok {
+		r["AWSRequestID"] = lc.AwsRequestID
+	}
+	return r
+}
+
+func (m *Metrics) write(r map[string]interface{}) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	w := m.Writer
+	if w == nil {
+		w = os.Stdout
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+func boolCount(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,146 @@
+package ridge_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fujiwara/ridge"
+)
+
+func TestMetricsEMF(t *testing.T) {
+	var buf bytes.Buffer
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "not found")
+	})
+	r := ridge.New(":8080", "/", mux)
+	r.Metrics = &ridge.Metrics{
+		Namespace: "test",
+		Dimensions: []ridge.MetricDimension{
+			{Name: "Route", Value: func(r *http.Request) string {
+				if strings.HasPrefix(r.URL.Path, "/users/") {
+					return "/users/{id}"
+				}
+				return ""
+			}},
+		},
+		Writer: &buf,
+		Local:  true,
+	}
+	req := httptest.NewRequest(http.MethodGet, "/users/123", nil)
+	req.Header.Set(ridge.PayloadVersionHeaderName, "2.0")
+	r.Handler().ServeHTTP(ridge.NewResponseWriter(), req)
+
+	var record struct {
+		AWS struct {
+			Timestamp         int64 `json:"Timestamp"`
+			CloudWatchMetrics []struct {
+				Namespace  string     `json:"Namespace"`
+				Dimensions [][]string `json:"Dimensions"`
+				Metrics    []struct {
+					Name string `json:"Name"`
+					Unit string `json:"Unit"`
+				} `json:"Metrics"`
+			} `json:"CloudWatchMetrics"`
+		} `json:"_aws"`
+		Route          string  `json:"Route"`
+		Latency        float64 `json:"Latency"`
+		ResponseSize   int64   `json:"ResponseSize"`
+		Status4xx      int     `json:"4xx"`
+		Status2xx      int     `json:"2xx"`
+		StatusCode     int     `json:"StatusCode"`
+		PayloadVersion string  `json:"PayloadVersion"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("failed to decode EMF record %q: %s", buf.String(), err)
+	}
+	if record.AWS.Timestamp == 0 {
+		t.Error("Timestamp is empty")
+	}
+	if len(record.AWS.CloudWatchMetrics) != 1 {
+		t.Fatalf("unexpected CloudWatchMetrics: %s", buf.String())
+	}
+	cm := record.AWS.CloudWatchMetrics[0]
+	if cm.Namespace != "test" {
+		t.Errorf("unexpected namespace: %s", cm.Namespace)
+	}
+	if len(cm.Dimensions) != 1 || len(cm.Dimensions[0]) != 1 || cm.Dimensions[0][0] != "Route" {
+		t.Errorf("unexpected dimensions: %v", cm.Dimensions)
+	}
+	if len(cm.Metrics) == 0 {
+		t.Error("metrics definitions are empty")
+	}
+	if record.Route != "/users/{id}" {
+		t.Errorf("unexpected route: %s", record.Route)
+	}
+	if record.ResponseSize != 9 {
+		t.Errorf("unexpected response size: %d", record.ResponseSize)
+	}
+	if record.Status4xx != 1 || record.Status2xx != 0 || record.StatusCode != 404 {
+		t.Errorf("unexpected status metrics: %s", buf.String())
+	}
+	if record.PayloadVersion != "2.0" {
+		t.Errorf("unexpected payload version: %s", record.PayloadVersion)
+	}
+}
+
+func TestMetricsDisabledOnLocal(t *testing.T) {
+	t.Setenv("AWS_EXECUTION_ENV", "")
+	t.Setenv("AWS_LAMBDA_RUNTIME_API", "")
+	var buf bytes.Buffer
+	r := ridge.New(":8080", "/", http.NotFoundHandler())
+	r.Metrics = &ridge.Metrics{Writer: &buf}
+	r.Handler().ServeHTTP(ridge.NewResponseWriter(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if buf.Len() != 0 {
+		t.Errorf("metrics must not be emitted on local without Local: %s", buf.String())
+	}
+}
+
+func TestMetricsDimensions(t *testing.T) {
+	var buf bytes.Buffer
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/", func(w http.ResponseWriter, r *http.Request) {
+		ridge.SetMetricDimension(r.Context(), "Route", "/users/{id}")
+	})
+	r := ridge.New(":8080", "/", http.StripPrefix("/api", mux))
+	r.Metrics = &ridge.Metrics{
+		Dimensions: []ridge.MetricDimension{
+			{Name: "Route"},
+			{Name: "Tenant", Value: func(r *http.Request) string { return r.Header.Get("X-Tenant") }},
+		},
+		Writer: &buf,
+		Local:  true,
+	}
+	for _, path := range []string{"/api/users/123", "/api/unknown"} {
+		buf.Reset()
+		r.Handler().ServeHTTP(ridge.NewResponseWriter(), httptest.NewRequest(http.MethodGet, path, nil))
+		var record struct {
+			AWS struct {
+				CloudWatchMetrics []struct {
+					Dimensions [][]string `json:"Dimensions"`
+				} `json:"CloudWatchMetrics"`
+			} `json:"_aws"`
+			Route  string `json:"Route"`
+			Tenant string `json:"Tenant"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+			t.Fatalf("failed to decode EMF record %q: %s", buf.String(), err)
+		}
+		if dims := record.AWS.CloudWatchMetrics[0].Dimensions; len(dims) != 1 || strings.Join(dims[0], ",") != "Route,Tenant" {
+			t.Errorf("%s: unexpected dimensions: %v", path, dims)
+		}
+		route := "-"
+		if path == "/api/users/123" {
+			route = "/users/{id}"
+		}
+		if record.Route != route || record.Tenant != "-" {
+			t.Errorf("%s: unexpected dimension values: %s", path, buf.String())
+		}
+	}
+}
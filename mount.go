@@ -0,0 +1,108 @@
+package ridge
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Mount represents a handler mounted on a host and a path prefix.
+type Mount struct {
+	// Host restricts the mount to requests for the host. It matches the Host header or requestContext.domainName.
+	// "*.example.com" matches subdomains of example.com. An empty host matches any hosts.
+	Host string
+	// Prefix is a path prefix to mount the handler, stripped in the same way as Ridge.Prefix.
+	Prefix string
+	// Handler serves requests for the mount.
+	Handler http.Handler
+}
+
+// mountRouter routes requests to mounts.
+// Mounts for specific hosts take precedence over mounts for any hosts, and longer prefixes take precedence.
+type mountRouter struct {
+	mounts   []mountEntry
+	notFound http.Handler
+}
+
+type mountEntry struct {
+	host    string
+	prefix  string
+	handler http.Handler
+}
+
+func (r *Ridge) router() http.Handler {
+	mounts := make([]Mount, 0, len(r.Mounts)+1)
+	mounts = append(mounts, r.Mounts...)
+	if r.Mux != nil {
+		mounts = append(mounts, Mount{Prefix: r.Prefix, Handler: r.Mux})
+	}
+	rt := &mountRouter{
+		mounts:   make([]mountEntry, 0, len(mounts)),
+		notFound: r.NotFoundHandler,
+	}
+	if rt.notFound == nil {
+		rt.notFound = http.NotFoundHandler()
+	}
+	for _, m := range mounts {
+		rt.mounts = append(rt.mounts, mountEntry{
+			host:    strings.ToLower(m.Host),
+			prefix:  strings.TrimSuffix(m.Prefix, "/"),
+			handler: mountPrefix(m.Prefix, m.Handler),
+		})
+	}
+	sort.SliceStable(rt.mounts, func(i, j int) bool {
+		a, b := rt.mounts[i], rt.mounts[j]
+		if (a.host != "") != (b.host != "") {
+			return a.host != ""
+		}
+		return len(a.prefix) > len(b.prefix)
+	})
+	return rt
+}
+
+// mountPrefix mounts h on the prefix and strips the prefix from the request path.
+func mountPrefix(prefix string, h http.Handler) http.Handler {
+	m := http.NewServeMux()
+	switch {
+	case prefix == "/", prefix == "":
+		m.Handle("/", h)
+	case !strings.HasSuffix(prefix, "/"):
+		m.Handle(prefix+"/", http.StripPrefix(prefix, h))
+	default:
+		m.Handle(prefix, http.StripPrefix(strings.TrimSuffix(prefix, "/"), h))
+	}
+	return m
+}
+
+func (rt *mountRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	host := strings.ToLower(req.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	var domainName string
+	if ec, ok := eventContextFrom(req.Context()); ok {
+		domainName = strings.ToLower(ec.domainName)
+	}
+	for _, m := range rt.mounts {
+		if m.host != "" && !matchHost(m.host, host) && !matchHost(m.host, domainName) {
+			continue
+		}
+		if m.prefix != "" && req.URL.Path != m.prefix && !strings.HasPrefix(req.URL.Path, m.prefix+"/") {
+			continue
+		}
+		m.handler.ServeHTTP(w, req)
+		return
+	}
+	rt.notFound.ServeHTTP(w, req)
+}
+
+func matchHost(pattern, host string) bool {
+	if host == "" {
+		return false
+	}
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(host, pattern[1:])
+	}
+	return pattern == host
+}
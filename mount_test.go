@@ -0,0 +1,90 @@
+package ridge_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fujiwara/ridge"
+)
+
+func namedHandler(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", name, r.URL.Path)
+	})
+}
+
+func TestMounts(t *testing.T) {
+	r := ridge.New("", "/", namedHandler("default"))
+	r.Mounts = []ridge.Mount{
+		{Prefix: "/api", Handler: namedHandler("api")},
+		{Prefix: "/api/v2/", Handler: namedHandler("api-v2")},
+		{Host: "admin.example.com", Prefix: "/", Handler: namedHandler("admin")},
+		{Host: "*.tenant.example.com", Prefix: "/api", Handler: namedHandler("tenant-api")},
+	}
+	tests := []struct {
+		host, path, expected string
+	}{
+		{"example.com", "/", "default /"},
+		{"example.com", "/users", "default /users"},
+		{"example.com", "/api/users", "api /users"},
+		{"example.com", "/api/v2/users", "api-v2 /users"},
+		{"example.com", "/apis", "default /apis"},
+		{"admin.example.com", "/api/users", "admin /api/users"},
+		{"ADMIN.example.com:8080", "/", "admin /"},
+		{"a.tenant.example.com", "/api/users", "tenant-api /users"},
+		{"a.tenant.example.com", "/users", "default /users"},
+	}
+	h := r.Handler()
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Host = tt.host
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if body := w.Body.String(); body != tt.expected {
+			t.Errorf("%s%s: unexpected %q, expected %q", tt.host, tt.path, body, tt.expected)
+		}
+	}
+}
+
+func TestMountsNotFound(t *testing.T) {
+	r := ridge.New("", "/api", namedHandler("api"))
+	r.NotFoundHandler = namedHandler("not-found")
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	if body := w.Body.String(); body != "not-found /users" {
+		t.Errorf("unexpected body: %s", body)
+	}
+
+	r = ridge.New("", "/api", namedHandler("api"))
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unexpected status: %d", w.Code)
+	}
+}
+
+func TestMountsDomainName(t *testing.T) {
+	r := ridge.New("", "/", namedHandler("default"))
+	r.Mounts = []ridge.Mount{
+		{Host: "api.example.com", Handler: namedHandler("custom-domain")},
+	}
+	event := ridge.RequestV2{
+		Version: "2.0",
+		RawPath: "/users",
+		Headers: map[string]string{"host": "abcdefg.execute-api.us-east-1.amazonaws.com"},
+	}
+	event.RequestContext.HTTP.Method = http.MethodGet
+	event.RequestContext.DomainName = "api.example.com"
+	b, _ := json.Marshal(event)
+	res, err := r.Invoke(context.Background(), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := res.(ridge.Response).Body; body != "custom-domain /users" {
+		t.Errorf("unexpected body: %s", body)
+	}
+}
@@ -0,0 +1,28 @@
+module github.com/fujiwara/ridge/otel
+
+go 1.23.0
+
+require (
+	github.com/aws/aws-lambda-go v1.48.0
+	github.com/fujiwara/ridge v0.14.0
+	go.opentelemetry.io/otel v1.38.0
+	go.opentelemetry.io/otel/sdk v1.38.0
+	go.opentelemetry.io/otel/trace v1.38.0
+)
+
+require (
+	github.com/go-logr/logr v1.4.3 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/pires/go-proxyproto v0.8.0 // indirect
+	go.opentelemetry.io/auto/sdk v1.1.0 // indirect
+	go.opentelemetry.io/otel/metric v1.38.0 // indirect
+	golang.org/x/net v0.34.0 // indirect
+	golang.org/x/sys v0.35.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+)
+
+// The replace directive is for development in this repository, and it is ignored by users of the module.
+// Middlewares, AfterInvocation and IsColdStart are available since ridge v0.14.0,
+// so otel must be tagged (otel/vX.Y.Z) after ridge v0.14.0 is tagged. Run "make check-otel-release" before tagging.
+replace github.com/fujiwara/ridge => ../
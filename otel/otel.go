@@ -0,0 +1,227 @@
+// Package otel provides OpenTelemetry instrumentation for ridge.
+//
+// It starts a server span per request on both of AWS Lambda runtime and net/http's server,
+// and flushes the tracer provider after each invocation on AWS Lambda runtime before the execution environment is frozen.
+package otel
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/fujiwara/ridge"
+	otelapi "go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope name.
+const ScopeName = "github.com/fujiwara/ridge/otel"
+
+// Attribute keys of OpenTelemetry semantic conventions.
+const (
+	AttrFaaSInvocationID   = attribute.Key("faas.invocation_id")
+	AttrFaaSColdStart      = attribute.Key("faas.coldstart")
+	AttrFaaSTrigger        = attribute.Key("faas.trigger")
+	AttrCloudProvider      = attribute.Key("cloud.provider")
+	AttrCloudResourceID    = attribute.Key("cloud.resource_id")
+	AttrHTTPRequestMethod  = attribute.Key("http.request.method")
+	AttrHTTPRoute          = attribute.Key("http.route")
+	AttrHTTPResponseStatus = attribute.Key("http.response.status_code")
+	AttrURLPath            = attribute.Key("url.path")
+	AttrURLQuery           = attribute.Key("url.query")
+	AttrServerAddress      = attribute.Key("server.address")
+	AttrClientAddress      = attribute.Key("client.address")
+	AttrUserAgentOriginal  = attribute.Key("user_agent.original")
+	AttrNetworkProtocolVer = attribute.Key("network.protocol.version")
+)
+
+// Option configures the instrumentation.
+type Option func(*config)
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	propagator     propagation.TextMapPropagator
+	routeFunc      func(*http.Request) string
+	flush          func(context.Context) error
+}
+
+// WithTracerProvider sets a TracerProvider. default is the global TracerProvider.
+// If the TracerProvider has ForceFlush method (e.g. *sdktrace.TracerProvider), it is called after each invocation on AWS Lambda runtime.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tp
+	}
+}
+
+// WithPropagator sets a TextMapPropagator to extract a parent span from request headers.
+// default is W3C Trace Context and Baggage.
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(c *config) {
+		c.propagator = p
+	}
+}
+
+// WithRouteFunc sets a function which returns a route pattern (http.route) of the request.
+func WithRouteFunc(f func(*http.Request) string) Option {
+	return func(c *config) {
+		c.routeFunc = f
+	}
+}
+
+// WithFlusher sets a function to flush exporters after each invocation on AWS Lambda runtime.
+func WithFlusher(f func(context.Context) error) Option {
+	return func(c *config) {
+		c.flush = f
+	}
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.tracerProvider == nil {
+		c.tracerProvider = otelapi.GetTracerProvider()
+	}
+	if c.propagator == nil {
+		c.propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+	}
+	if c.flush == nil {
+		if f, ok := c.tracerProvider.(interface{ ForceFlush(context.Context) error }); ok {
+			c.flush = f.ForceFlush
+		}
+	}
+	return c
+}
+
+// Instrument instruments r with OpenTelemetry.
+func Instrument(r *ridge.Ridge, opts ...Option) {
+	c := newConfig(opts)
+	r.Middlewares = append(r.Middlewares, c.middleware)
+	if c.flush != nil {
+		r.AfterInvocation = append(r.AfterInvocation, func(ctx context.Context) {
+			if err := c.flush(ctx); err != nil {
+				log.Println("failed to flush telemetry:", err)
+			}
+		})
+	}
+}
+
+// Middleware returns a middleware which starts a server span per request.
+func Middleware(opts ...Option) func(http.Handler) http.Handler {
+	return newConfig(opts).middleware
+}
+
+func (c *config) middleware(next http.Handler) http.Handler {
+	tracer := c.tracerProvider.Tracer(ScopeName)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := c.propagator.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		var route string
+		if c.routeFunc != nil {
+			route = c.routeFunc(req)
+		}
+		name := req.Method
+		if route != "" {
+			name = req.Method + " " + route
+		}
+		ctx, span := tracer.Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(c.attributes(req, route)...),
+		)
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, req.WithContext(ctx))
+
+		span.SetAttributes(AttrHTTPResponseStatus.Int(rec.status))
+		if rec.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
+
+func (c *config) attributes(req *http.Request, route string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		AttrFaaSTrigger.String("http"),
+		AttrFaaSColdStart.Bool(ridge.IsColdStart(req.Context())),
+		AttrHTTPRequestMethod.String(req.Method),
+		AttrURLPath.String(req.URL.Path),
+	}
+	if route != "" {
+		attrs = append(attrs, AttrHTTPRoute.String(route))
+	}
+	if req.URL.RawQuery != "" {
+		attrs = append(attrs, AttrURLQuery.String(req.URL.RawQuery))
+	}
+	if req.Host != "" {
+		attrs = append(attrs, AttrServerAddress.String(req.Host))
+	}
+	if ip := clientAddress(req.RemoteAddr); ip != "" {
+		attrs = append(attrs, AttrClientAddress.String(ip))
+	}
+	if ua := req.UserAgent(); ua != "" {
+		attrs = append(attrs, AttrUserAgentOriginal.String(ua))
+	}
+	if req.ProtoMajor > 0 {
+		attrs = append(attrs, AttrNetworkProtocolVer.String(protocolVersion(req)))
+	}
+	if lc, ok := lambdacontext.FromContext(req.Context()); ok {
+		attrs = append(attrs,
+			AttrCloudProvider.String("aws"),
+			AttrFaaSInvocationID.String(lc.AwsRequestID),
+			AttrCloudResourceID.String(lc.InvokedFunctionArn),
+		)
+	}
+	return attrs
+}
+
+func clientAddress(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.TrimSpace(addr)
+}
+
+func protocolVersion(req *http.Request) string {
+	if req.ProtoMinor == 0 && req.ProtoMajor >= 2 {
+		return fmt.Sprint(req.ProtoMajor)
+	}
+	return fmt.Sprintf("%d.%d", req.ProtoMajor, req.ProtoMinor)
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusRecorder) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wroteHeader = true
+		f.Flush()
+	}
+}
+
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,104 @@
+package otel_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/fujiwara/ridge"
+	ridgeotel "github.com/fujiwara/ridge/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddleware(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	mw := ridgeotel.Middleware(
+		ridgeotel.WithTracerProvider(tp),
+		ridgeotel.WithRouteFunc(func(r *http.Request) string { return "/users/{id}" }),
+	)
+	h := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !trace.SpanContextFromContext(r.Context()).IsValid() {
+			t.Error("span context is not found in the request context")
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1?x=y", nil)
+	req.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{
+		AwsRequestID:       "aws-request-id",
+		InvokedFunctionArn: "arn:aws:lambda:ap-northeast-1:123456789012:function:ridge",
+	})
+	h.ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+
+	spans := sr.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("unexpected number of spans: %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /users/{id}" {
+		t.Errorf("unexpected span name: %s", span.Name())
+	}
+	if span.SpanKind() != trace.SpanKindServer {
+		t.Errorf("unexpected span kind: %s", span.SpanKind())
+	}
+	if span.Parent().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("unexpected parent trace ID: %s", span.Parent().TraceID())
+	}
+	if span.Status().Code != codes.Error {
+		t.Errorf("unexpected status: %v", span.Status())
+	}
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	expected := map[attribute.Key]attribute.Value{
+		ridgeotel.AttrFaaSInvocationID:   attribute.StringValue("aws-request-id"),
+		ridgeotel.AttrCloudResourceID:    attribute.StringValue("arn:aws:lambda:ap-northeast-1:123456789012:function:ridge"),
+		ridgeotel.AttrHTTPRoute:          attribute.StringValue("/users/{id}"),
+		ridgeotel.AttrHTTPResponseStatus: attribute.IntValue(http.StatusServiceUnavailable),
+		ridgeotel.AttrHTTPRequestMethod:  attribute.StringValue("GET"),
+	}
+	for k, v := range expected {
+		if attrs[k] != v {
+			t.Errorf("%s: expected %v, got %v", k, v.Emit(), attrs[k].Emit())
+		}
+	}
+	if _, ok := attrs[ridgeotel.AttrFaaSColdStart]; !ok {
+		t.Errorf("%s is not found", ridgeotel.AttrFaaSColdStart)
+	}
+}
+
+func TestInstrument(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	r := ridge.New(":8080", "/", http.NotFoundHandler())
+	ridgeotel.Instrument(r, ridgeotel.WithTracerProvider(tp))
+	if len(r.Middlewares) != 1 {
+		t.Fatalf("unexpected middlewares: %d", len(r.Middlewares))
+	}
+	if len(r.AfterInvocation) != 1 {
+		t.Fatalf("ForceFlush of the tracer provider is not registered: %d", len(r.AfterInvocation))
+	}
+	flushed := false
+	ridgeotel.Instrument(r,
+		ridgeotel.WithTracerProvider(tp),
+		ridgeotel.WithFlusher(func(context.Context) error {
+			flushed = true
+			return nil
+		}),
+	)
+	for _, hook := range r.AfterInvocation {
+		hook(context.Background())
+	}
+	if !flushed {
+		t.Error("flusher is not called")
+	}
+}
@@ -0,0 +1,164 @@
+package ridge
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+
+	proxyproto "github.com/pires/go-proxyproto"
+	"github.com/pires/go-proxyproto/tlvparse"
+)
+
+// ProxyProtocolPolicy is a policy of PROXY protocol headers sent from upstreams.
+type ProxyProtocolPolicy string
+
+const (
+	// ProxyProtocolUse uses the address in a PROXY protocol header if present. It is the default policy.
+	ProxyProtocolUse ProxyProtocolPolicy = "use"
+	// ProxyProtocolRequire rejects connections without a PROXY protocol header.
+	ProxyProtocolRequire ProxyProtocolPolicy = "require"
+	// ProxyProtocolIgnore accepts a PROXY protocol header but ignores the address in it.
+	ProxyProtocolIgnore ProxyProtocolPolicy = "ignore"
+	// ProxyProtocolReject rejects connections with a PROXY protocol header.
+	ProxyProtocolReject ProxyProtocolPolicy = "reject"
+)
+
+// ProxyProtocolConfig represents a configuration of PROXY protocol on net/http's server.
+type ProxyProtocolConfig struct {
+	// Policy is a policy for upstreams which do not match any rule. default is ProxyProtocolUse.
+	Policy ProxyProtocolPolicy
+	// Rules are policies per upstream CIDR. The first matched rule is applied.
+	Rules []ProxyProtocolRule
+	// ReadHeaderTimeout is a timeout to read a PROXY protocol header.
+	// default is proxyproto.DefaultReadHeaderTimeout. A negative value disables the timeout.
+	ReadHeaderTimeout time.Duration
+}
+
+// ProxyProtocolRule represents a policy for upstreams in the CIDR.
+type ProxyProtocolRule struct {
+	CIDR   string
+	Policy ProxyProtocolPolicy
+}
+
+// ProxyProtocolInfo represents a PROXY protocol header received on the connection of a request.
+type ProxyProtocolInfo struct {
+	SourceAddr      net.Addr
+	DestinationAddr net.Addr
+	// TLVs are Type-Length-Value vectors of a PROXY protocol v2 header.
+	TLVs []proxyproto.TLV
+	// VPCEndpointID is an ID of the VPC endpoint sent by NLB with PrivateLink.
+	VPCEndpointID string
+	// Authority is a host name sent by the client with SNI.
+	Authority string
+	// SSL is information of the TLS connection terminated by the upstream, if any.
+	SSL *tlvparse.PP2SSL
+}
+
+func toProxyprotoPolicy(p ProxyProtocolPolicy) (proxyproto.Policy, error) {
+	switch p {
+	case ProxyProtocolUse, "":
+		return proxyproto.USE, nil
+	case ProxyProtocolRequire:
+		return proxyproto.REQUIRE, nil
+	case ProxyProtocolIgnore:
+		return proxyproto.IGNORE, nil
+	case ProxyProtocolReject:
+		return proxyproto.REJECT, nil
+	default:
+		return proxyproto.USE, fmt.Errorf("unknown PROXY protocol policy: %s", p)
+	}
+}
+
+type proxyProtocolCIDRPolicy struct {
+	ipnet  *net.IPNet
+	policy proxyproto.Policy
+}
+
+// listener wraps l to accept PROXY protocol headers by the configuration.
+func (c *ProxyProtocolConfig) listener(l net.Listener) (net.Listener, error) {
+	def, err := toProxyprotoPolicy(c.Policy)
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]proxyProtocolCIDRPolicy, 0, len(c.Rules))
+	for _, rule := range c.Rules {
+		_, ipnet, err := net.ParseCIDR(rule.CIDR)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR of PROXY protocol rule: %w", err)
+		}
+		p, err := toProxyprotoPolicy(rule.Policy)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, proxyProtocolCIDRPolicy{ipnet: ipnet, policy: p})
+	}
+	return &proxyproto.Listener{
+		Listener:          l,
+		ReadHeaderTimeout: c.ReadHeaderTimeout,
+		ConnPolicy: func(opts proxyproto.ConnPolicyOptions) (proxyproto.Policy, error) {
+			if len(rules) == 0 {
+				return def, nil
+			}
+			addr, ok := opts.Upstream.(*net.TCPAddr)
+			if !ok {
+				return def, nil
+			}
+			for _, rule := range rules {
+				if rule.ipnet.Contains(addr.IP) {
+					return rule.policy, nil
+				}
+			}
+			return def, nil
+		},
+	}, nil
+}
+
+type proxyProtocolConnKey struct{}
+
+// withProxyProtocolConn stores the connection to the context, to read the PROXY protocol header in handlers.
+// The header is read lazily, because ConnContext is called in the accepting goroutine.
+// A connection violating the policy fails on reading, and net/http's server responds 400 Bad Request without calling handlers.
+// With TLS, the connection is *tls.Conn wrapping the connection of the PROXY protocol listener.
+func withProxyProtocolConn(ctx context.Context, c net.Conn) context.Context {
+	if tc, ok := c.(*tls.Conn); ok {
+		c = tc.NetConn()
+	}
+	if pc, ok := c.(*proxyproto.Conn); ok {
+		return context.WithValue(ctx, proxyProtocolConnKey{}, pc)
+	}
+	return ctx
+}
+
+// ProxyProtocolInfoFromContext returns a PROXY protocol header received on the connection of the request.
+// It returns false when the connection has no PROXY protocol header.
+func ProxyProtocolInfoFromContext(ctx context.Context) (*ProxyProtocolInfo, bool) {
+	pc, ok := ctx.Value(proxyProtocolConnKey{}).(*proxyproto.Conn)
+	if !ok {
+		return nil, false
+	}
+	h := pc.ProxyHeader()
+	if h == nil {
+		return nil, false
+	}
+	info := &ProxyProtocolInfo{
+		SourceAddr:      h.SourceAddr,
+		DestinationAddr: h.DestinationAddr,
+	}
+	tlvs, err := h.TLVs()
+	if err != nil {
+		return info, true
+	}
+	info.TLVs = tlvs
+	info.VPCEndpointID = tlvparse.FindAWSVPCEndpointID(tlvs)
+	for _, tlv := range tlvs {
+		if tlv.Type == proxyproto.PP2_TYPE_AUTHORITY {
+			info.Authority = string(tlv.Value)
+		}
+	}
+	if ssl, ok := tlvparse.FindSSL(tlvs); ok {
+		info.SSL = &ssl
+	}
+	return info, true
+}
@@ -0,0 +1,225 @@
+package ridge_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fujiwara/ridge"
+	proxyproto "github.com/pires/go-proxyproto"
+)
+
+func startProxyProtocolServer(t *testing.T, c *ridge.ProxyProtocolConfig) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pl, err := c.Listener(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			info, ok := ridge.ProxyProtocolInfoFromContext(r.Context())
+			if !ok {
+				fmt.Fprintf(w, "handled none %s", r.RemoteAddr)
+				return
+			}
+			fmt.Fprintf(w, "handled %s %s %s", r.RemoteAddr, info.VPCEndpointID, info.Authority)
+		}),
+		ConnContext: ridge.WithProxyProtocolConn,
+	}
+	go srv.Serve(pl)
+	t.Cleanup(func() { srv.Close() })
+	return l.Addr().String()
+}
+
+func proxyProtocolGet(t *testing.T, addr string, header *proxyproto.Header) (string, error) {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if header != nil {
+		if _, err := header.WriteTo(conn); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fmt.Fprint(conn, "GET / HTTP/1.1\r\nHost: example.com\r\nConnection: close\r\n\r\n")
+	res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	return string(b), err
+}
+
+func newProxyProtocolHeader(t *testing.T) *proxyproto.Header {
+	h := &proxyproto.Header{
+		Version:           2,
+		Command:           proxyproto.PROXY,
+		TransportProtocol: proxyproto.TCPv4,
+		SourceAddr:        &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 12345},
+		DestinationAddr:   &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 80},
+	}
+	err := h.SetTLVs([]proxyproto.TLV{
+		{Type: proxyproto.PP2_TYPE_AUTHORITY, Value: []byte("example.com")},
+		{Type: 0xEA, Value: append([]byte{0x01}, "vpce-0123456789abcdef0"...)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestProxyProtocolTLVs(t *testing.T) {
+	addr := startProxyProtocolServer(t, &ridge.ProxyProtocolConfig{})
+	body, err := proxyProtocolGet(t, addr, newProxyProtocolHeader(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "handled 192.0.2.1:12345 vpce-0123456789abcdef0 example.com" {
+		t.Errorf("unexpected body: %s", body)
+	}
+
+	body, err = proxyProtocolGet(t, addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(body, "handled none ") {
+		t.Errorf("unexpected body: %s", body)
+	}
+}
+
+func TestProxyProtocolTLS(t *testing.T) {
+	// borrow a self-signed certificate from httptest
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	r := ridge.New("", "/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info, ok := ridge.ProxyProtocolInfoFromContext(r.Context())
+		if !ok {
+			fmt.Fprintf(w, "handled none %s", r.RemoteAddr)
+			return
+		}
+		fmt.Fprintf(w, "handled %s %s %s", r.RemoteAddr, info.VPCEndpointID, info.Authority)
+	}))
+	r.TLSConfig = &tls.Config{Certificates: ts.TLS.Certificates}
+	r.ProxyProtocol = true
+	addr := startServer(t, r)
+
+	client := ts.Client()
+	tr := client.Transport.(*http.Transport)
+	tr.DisableKeepAlives = true
+	tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		// the PROXY protocol header precedes the TLS handshake.
+		if _, err := newProxyProtocolHeader(t).WriteTo(conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+	if body := getBody(t, client, "https://"+addr+"/"); body != "handled 192.0.2.1:12345 vpce-0123456789abcdef0 example.com" {
+		t.Errorf("unexpected body: %s", body)
+	}
+}
+
+func TestProxyProtocolPolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     *ridge.ProxyProtocolConfig
+		withHeader bool
+		fail       bool
+		body       string
+	}{
+		{
+			name:   "require without header",
+			config: &ridge.ProxyProtocolConfig{Policy: ridge.ProxyProtocolRequire},
+			fail:   true,
+		},
+		{
+			name:       "reject with header",
+			config:     &ridge.ProxyProtocolConfig{Policy: ridge.ProxyProtocolReject},
+			withHeader: true,
+			fail:       true,
+		},
+		{
+			name:       "ignore with header",
+			config:     &ridge.ProxyProtocolConfig{Policy: ridge.ProxyProtocolIgnore},
+			withHeader: true,
+		},
+		{
+			name: "rule matched",
+			config: &ridge.ProxyProtocolConfig{
+				Policy: ridge.ProxyProtocolReject,
+				Rules:  []ridge.ProxyProtocolRule{{CIDR: "127.0.0.0/8", Policy: ridge.ProxyProtocolRequire}},
+			},
+			withHeader: true,
+			body:       "handled 192.0.2.1:12345 vpce-0123456789abcdef0 example.com",
+		},
+		{
+			name: "rule not matched",
+			config: &ridge.ProxyProtocolConfig{
+				Rules: []ridge.ProxyProtocolRule{{CIDR: "10.0.0.0/8", Policy: ridge.ProxyProtocolRequire}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := startProxyProtocolServer(t, tt.config)
+			var h *proxyproto.Header
+			if tt.withHeader {
+				h = newProxyProtocolHeader(t)
+			}
+			body, err := proxyProtocolGet(t, addr, h)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.fail {
+				if strings.HasPrefix(body, "handled") {
+					t.Errorf("the connection must be rejected: %s", body)
+				}
+				return
+			}
+			if !strings.HasPrefix(body, "handled") {
+				t.Errorf("the request is not handled: %s", body)
+			}
+			if tt.body != "" && body != tt.body {
+				t.Errorf("unexpected body: %s", body)
+			}
+		})
+	}
+}
+
+func TestProxyProtocolConfigInvalid(t *testing.T) {
+	for _, c := range []*ridge.ProxyProtocolConfig{
+		{Policy: "unknown"},
+		{Rules: []ridge.ProxyProtocolRule{{CIDR: "invalid", Policy: ridge.ProxyProtocolUse}}},
+	} {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Listener(l); err == nil {
+			t.Errorf("expected error for %#v", c)
+		}
+		l.Close()
+	}
+}
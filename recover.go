@@ -0,0 +1,228 @@
+package ridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"mime"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+)
+
+// withRecover recovers panics in next and responds 500 Internal Server Error by ErrorPage.
+// When the response is in progress, a buffered response on AWS Lambda is replaced by the error page,
+// a streaming response is terminated by CloseWithError, and a response on net/http's server is aborted by http.ErrAbortHandler.
+func (r *Ridge) withRecover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		rec := newResponseRecorder(w)
+		// headers set by the outer layers (e.g. ColdStartHeader) are restored when a buffered response is replaced.
+		var outer http.Header
+		if isBufferedResponse(w) {
+			outer = w.Header().Clone()
+		}
+		defer func() {
+			if v := recover(); v != nil {
+				r.recovered(rec, req, v, outer)
+			}
+		}()
+		next.ServeHTTP(rec, req)
+	})
+}
+
+func isBufferedResponse(w http.ResponseWriter) bool {
+	buffered := false
+	unwrapResponseWriter(w, func(w http.ResponseWriter) {
+		if _, ok := w.(*ResponseWriter); ok {
+			buffered = true
+		}
+	})
+	return buffered
+}
+
+func (r *Ridge) recovered(rec *responseRecorder, req *http.Request, v interface{}, outer http.Header) {
+	abort := v == http.ErrAbortHandler
+	if !abort {
+		log.Printf("panic serving %s %s (request id %s): %v\n%s", req.Method, req.URL.Path, requestIDOf(req), v, debug.Stack())
+	}
+	markPanicked(rec.ResponseWriter)
+
+	var buffered *ResponseWriter
+	var streaming *StreamingResponseWriter
+	unwrapResponseWriter(rec.ResponseWriter, func(w http.ResponseWriter) {
+		switch w := w.(type) {
+		case *ResponseWriter:
+			buffered = w
+		case *StreamingResponseWriter:
+			streaming = w
+		}
+	})
+	switch {
+	case streaming != nil && (abort || rec.wroteHeader):
+		err, ok := v.(error)
+		if !ok {
+			err = fmt.Errorf("panic: %v", v)
+		}
+		streaming.CloseWithError(err)
+	case buffered != nil && rec.wroteHeader:
+		// the response is not sent yet, so it can be replaced.
+		// The error page is written through the recorders to be recorded by access logs and metrics.
+		buffered.reset()
+		for k, vs := range outer {
+			buffered.Header()[k] = vs
+		}
+		unwrapResponseWriter(rec, func(w http.ResponseWriter) {
+			if rec, ok := w.(*responseRecorder); ok {
+				rec.reset()
+			}
+		})
+		r.writeErrorPage(rec, req, http.StatusInternalServerError)
+	case buffered == nil && streaming == nil && (abort || rec.wroteHeader):
+		// net/http's server closes the connection without logging.
+		panic(http.ErrAbortHandler)
+	default:
+		h := rec.Header()
+		for _, key := range []string{"Content-Encoding", "Content-Length", "Content-Range", "Content-Disposition", "ETag", "Last-Modified"} {
+			h.Del(key)
+		}
+		r.writeErrorPage(rec, req, http.StatusInternalServerError)
+	}
+}
+
+// markPanicked marks recorders wrapping w to record the panic in access logs and metrics.
+func markPanicked(w http.ResponseWriter) {
+	unwrapResponseWriter(w, func(w http.ResponseWriter) {
+		if rec, ok := w.(*responseRecorder); ok {
+			rec.panicked = true
+			rec.status = http.StatusInternalServerError
+		}
+	})
+}
+
+// unwrapResponseWriter calls f with w and response writers wrapped by w, by Unwrap() as http.ResponseController does.
+func unwrapResponseWriter(w http.ResponseWriter, f func(http.ResponseWriter)) {
+	for w != nil {
+		f(w)
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return
+		}
+		w = u.Unwrap()
+	}
+}
+
+func requestIDOf(req *http.Request) string {
+	if lc, ok := lambdacontext.FromContext(req.Context()); ok {
+		return lc.AwsRequestID
+	}
+	if id := req.Header.Get(RequestIDHeaderName); id != "" {
+		return id
+	}
+	return "-"
+}
+
+func (r *Ridge) writeErrorPage(w http.ResponseWriter, req *http.Request, status int) {
+	if r.ErrorPage != nil {
+		r.ErrorPage(w, req, status)
+		return
+	}
+	WriteErrorPage(w, req, status)
+}
+
+type errorDocument struct {
+	Type      string `json:"type,omitempty"`
+	Title     string `json:"title,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Status    int    `json:"status"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// WriteErrorPage writes an error response of the status code in the format negotiated by Accept of req.
+// application/problem+json (RFC 9457), application/json, text/html and text/plain (default) are supported.
+// It is the default of Ridge.ErrorPage.
+func WriteErrorPage(w http.ResponseWriter, req *http.Request, status int) {
+	title := http.StatusText(status)
+	id := requestIDOf(req)
+	if id == "-" {
+		id = ""
+	}
+	h := w.Header()
+	h.Set("Cache-Control", "no-store")
+	h.Set("X-Content-Type-Options", "nosniff")
+	switch negotiateErrorFormat(req.Header.Get("Accept")) {
+	case "application/problem+json":
+		h.Set("Content-Type", "application/problem+json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(errorDocument{Type: "about:blank", Title: title, Status: status, Instance: req.URL.Path, RequestID: id})
+	case "application/json":
+		h.Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(errorDocument{Error: title, Status: status, RequestID: id})
+	case "text/html":
+		h.Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		t := html.EscapeString(strconv.Itoa(status) + " " + title)
+		fmt.Fprintf(w, "<!DOCTYPE html>\n<html><head><title>%s</title></head><body><h1>%s</h1>", t, t)
+		if id != "" {
+			fmt.Fprintf(w, "<p>Request ID: %s</p>", html.EscapeString(id))
+		}
+		fmt.Fprintln(w, "</body></html>")
+	default:
+		h.Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		fmt.Fprintln(w, title)
+	}
+}
+
+// errorFormats are formats of error pages. For wildcards (e.g. "application/*"), the former is preferred.
+var errorFormats = []string{"application/json", "application/problem+json", "text/plain", "text/html"}
+
+// negotiateErrorFormat returns the most preferred format of errorFormats for Accept.
+func negotiateErrorFormat(accept string) string {
+	type mediaRange struct {
+		typ string
+		q   float64
+	}
+	var ranges []mediaRange
+	for _, s := range strings.Split(accept, ",") {
+		typ, params, err := mime.ParseMediaType(strings.TrimSpace(s))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, mediaRange{typ, q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+	for _, mr := range ranges {
+		for _, f := range errorFormats {
+			if matchMediaRange(mr.typ, f) {
+				return f
+			}
+		}
+	}
+	return "text/plain"
+}
+
+func matchMediaRange(mr, typ string) bool {
+	switch {
+	case mr == "*/*":
+		return typ == "text/plain"
+	case strings.HasSuffix(mr, "/*"):
+		return strings.HasPrefix(typ, strings.TrimSuffix(mr, "*"))
+	default:
+		return mr == typ
+	}
+}
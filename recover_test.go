@@ -0,0 +1,244 @@
+package ridge_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fujiwara/ridge"
+)
+
+func panicHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		panic("boom")
+	})
+	mux.HandleFunc("/panic-in-progress", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "partial")
+		w.(http.Flusher).Flush()
+		panic("boom")
+	})
+	mux.HandleFunc("/abort", func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+	return mux
+}
+
+func transportClient(r *ridge.Ridge) *http.Client {
+	return &http.Client{
+		Transport: &ridge.Transport{Invoker: ridge.NewHandlerInvoker(r)},
+	}
+}
+
+func TestRecoverOnLambda(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		t.Run(fmt.Sprintf("streaming=%v", streaming), func(t *testing.T) {
+			r := ridge.New("", "/", panicHandler())
+			r.StreamingResponse = streaming
+			res, err := transportClient(r).Get("http://example.com/panic")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			b, _ := io.ReadAll(res.Body)
+			if res.StatusCode != http.StatusInternalServerError {
+				t.Errorf("unexpected status code: %d", res.StatusCode)
+			}
+			if string(b) != "Internal Server Error\n" {
+				t.Errorf("unexpected body: %s", b)
+			}
+			if v := res.Header.Get("Content-Encoding"); v != "" {
+				t.Errorf("unexpected Content-Encoding: %s", v)
+			}
+		})
+	}
+}
+
+func TestRecoverInProgress(t *testing.T) {
+	t.Run("buffered", func(t *testing.T) {
+		r := ridge.New("", "/", panicHandler())
+		res, err := transportClient(r).Get("http://example.com/panic-in-progress")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, _ := io.ReadAll(res.Body)
+		if res.StatusCode != http.StatusInternalServerError || string(b) != "Internal Server Error\n" {
+			t.Errorf("unexpected response: %d %s", res.StatusCode, b)
+		}
+	})
+	t.Run("streaming", func(t *testing.T) {
+		r := ridge.New("", "/", panicHandler())
+		r.StreamingResponse = true
+		res, err := transportClient(r).Get("http://example.com/panic-in-progress")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, err := io.ReadAll(res.Body)
+		if err == nil || !strings.Contains(err.Error(), "boom") {
+			t.Errorf("expected the stream to be terminated, got %v", err)
+		}
+		if res.StatusCode != http.StatusOK || string(b) != "partial" {
+			t.Errorf("unexpected response: %d %s", res.StatusCode, b)
+		}
+	})
+	t.Run("local", func(t *testing.T) {
+		r := ridge.New("", "/", panicHandler())
+		addr := startServer(t, r)
+		res, err := http.Get("http://" + addr + "/panic-in-progress")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if _, err := io.ReadAll(res.Body); err == nil {
+			t.Error("expected the response to be aborted")
+		}
+	})
+}
+
+func TestRecoverInProgressAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	r := ridge.New("", "/", panicHandler())
+	r.AccessLog = &ridge.AccessLog{Format: ridge.AccessLogFormatJSON, Writer: &buf}
+	r.ColdStartHeader = "X-Cold-Start"
+	res, err := transportClient(r).Get("http://example.com/panic-in-progress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusInternalServerError || string(b) != "Internal Server Error\n" {
+		t.Errorf("unexpected response: %d %s", res.StatusCode, b)
+	}
+	if res.Header.Get("X-Cold-Start") == "" {
+		t.Error("the cold start header is dropped")
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("failed to decode access log %q: %s", buf.String(), err)
+	}
+	if entry["status"] != float64(http.StatusInternalServerError) || entry["bytes"] != float64(len(b)) {
+		t.Errorf("the access log does not match the response: %s", buf.String())
+	}
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	r := ridge.New("", "/", http.NotFoundHandler())
+	r.Middlewares = []func(http.Handler) http.Handler{
+		func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) { panic("boom") })
+		},
+	}
+	res, err := transportClient(r).Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusInternalServerError {
+		t.Errorf("unexpected status code: %d", res.StatusCode)
+	}
+}
+
+func TestRecoverLocal(t *testing.T) {
+	r := ridge.New("", "/", panicHandler())
+	addr := startServer(t, r)
+	res, err := http.Get("http://" + addr + "/panic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusInternalServerError || string(b) != "Internal Server Error\n" {
+		t.Errorf("unexpected response: %d %s", res.StatusCode, b)
+	}
+
+	if _, err := http.Get("http://" + addr + "/abort"); err == nil {
+		t.Error("expected the connection to be aborted")
+	}
+}
+
+func TestRecoverStreamingAbort(t *testing.T) {
+	r := ridge.New("", "/", panicHandler())
+	r.StreamingResponse = true
+	res, err := transportClient(r).Get("http://example.com/abort")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if _, err := io.ReadAll(res.Body); err == nil {
+		t.Error("expected the stream to be terminated")
+	}
+}
+
+func TestRecoverErrorPage(t *testing.T) {
+	r := ridge.New("", "/", panicHandler())
+	r.ErrorPage = func(w http.ResponseWriter, req *http.Request, status int) {
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "custom %d", status)
+	}
+	res, err := transportClient(r).Get("http://example.com/panic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusInternalServerError || string(b) != "custom 500" {
+		t.Errorf("unexpected response: %d %s", res.StatusCode, b)
+	}
+}
+
+func TestRecoverMetrics(t *testing.T) {
+	var buf bytes.Buffer
+	r := ridge.New("", "/", panicHandler())
+	r.Metrics = &ridge.Metrics{Writer: &buf, Local: true}
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	r.Handler().ServeHTTP(httptest.NewRecorder(), req)
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record[ridge.MetricPanic] != 1.0 || record[ridge.Metric5xx] != 1.0 {
+		t.Errorf("unexpected metrics: %s", buf.String())
+	}
+}
+
+func TestWriteErrorPage(t *testing.T) {
+	tests := []struct {
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"", "text/plain; charset=utf-8", "Internal Server Error\n"},
+		{"*/*", "text/plain; charset=utf-8", "Internal Server Error\n"},
+		{"text/html,application/xhtml+xml,*/*;q=0.8", "text/html; charset=utf-8", "<h1>500 Internal Server Error</h1>"},
+		{"application/json", "application/json", `{"error":"Internal Server Error","status":500,"request_id":"req-1"}`},
+		{"application/problem+json, application/json;q=0.9", "application/problem+json", `{"type":"about:blank","title":"Internal Server Error","status":500,"instance":"/users","request_id":"req-1"}`},
+		{"text/html;q=0.5, application/json", "application/json", `"error"`},
+		{"application/*", "application/json", `"error"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			req.Header.Set("Accept", tt.accept)
+			req.Header.Set(ridge.RequestIDHeaderName, "req-1")
+			w := httptest.NewRecorder()
+			ridge.WriteErrorPage(w, req, http.StatusInternalServerError)
+			if w.Code != http.StatusInternalServerError {
+				t.Errorf("unexpected status code: %d", w.Code)
+			}
+			if v := w.Header().Get("Content-Type"); v != tt.contentType {
+				t.Errorf("unexpected Content-Type: %s", v)
+			}
+			if !strings.Contains(w.Body.String(), tt.body) {
+				t.Errorf("unexpected body: %s", w.Body.String())
+			}
+		})
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io"
 	"net/http"
 	"net/url"
@@ -44,9 +44,11 @@ type Request = RequestV1
 func NewRequest(event json.RawMessage) (*http.Request, error) {
 	var r struct {
 		Version string `json:"version"`
+		RawPath string `json:"rawPath"`
+		Method  string `json:"method"`
 	}
 	if PayloadVersion == "" {
-		if err := json.Unmarshal(event, &r); err != nil {
+		if err := decodeEvent(event, &r); err != nil {
 			return nil, err
 		}
 	} else {
@@ -55,8 +57,20 @@ func NewRequest(event json.RawMessage) (*http.Request, error) {
 
 	switch r.Version {
 	case "2.0":
+		if isLatticeEvent(r.Version, r.RawPath, r.Method) {
+			var rl RequestLattice
+			if err := decodeEvent(event, &rl); err != nil {
+				return nil, err
+			}
+			req, err := rl.httpRequest()
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set(PayloadVersionHeaderName, r.Version)
+			return req, nil
+		}
 		var rv2 RequestV2
-		if err := json.Unmarshal(event, &rv2); err != nil {
+		if err := decodeEvent(event, &rv2); err != nil {
 			return nil, err
 		}
 		req, err := rv2.httpRequest()
@@ -67,7 +81,7 @@ func NewRequest(event json.RawMessage) (*http.Request, error) {
 		return req, nil
 	case "1.0", "":
 		var rv1 RequestV1
-		if err := json.Unmarshal(event, &rv1); err != nil {
+		if err := decodeEvent(event, &rv1); err != nil {
 			return nil, err
 		}
 		req, err := rv1.httpRequest()
@@ -79,8 +93,31 @@ func NewRequest(event json.RawMessage) (*http.Request, error) {
 		}
 		return req, nil
 	default:
-		return nil, fmt.Errorf("payload Version %s is not supported", r.Version)
+		return nil, unsupportedPayloadVersionError(r.Version)
+	}
+}
+
+func decodeEvent(event json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(event, v); err != nil {
+		var te *json.UnmarshalTypeError
+		if errors.As(err, &te) && te.Field != "" {
+			return invalidEvent(te.Field, err)
+		}
+		return invalidEvent("event", err)
+	}
+	return nil
+}
+
+func decodeBody(body string, isBase64Encoded bool) (io.Reader, int64, error) {
+	if !isBase64Encoded {
+		return strings.NewReader(body), int64(len(body)), nil
 	}
+	raw := make([]byte, base64.StdEncoding.DecodedLen(len(body)))
+	n, err := base64.StdEncoding.Decode(raw, []byte(body))
+	if err != nil {
+		return nil, 0, invalidEvent("body", err)
+	}
+	return bytes.NewReader(raw[0:n]), int64(n), nil
 }
 
 func (r RequestV1) httpRequest() (*http.Request, error) {
@@ -101,36 +138,22 @@ func (r RequestV1) httpRequest() (*http.Request, error) {
 	if id := r.RequestContext.RequestID; id != "" {
 		header.Set(RequestIDHeaderName, id)
 	}
-	v := make(url.Values)
-	if len(r.MultiValueQueryStringParameters) > 0 {
-		for key, values := range r.MultiValueQueryStringParameters {
-			for _, value := range values {
-				v.Add(key, value)
-			}
-		}
+	v := queryValues(r.MultiValueQueryStringParameters, r.QueryStringParameters)
+	var u *url.URL
+	var uri string
+	var err error
+	if r.RequestContext.ELB != nil {
+		// ALB passes the path and the query string parameters as sent by the client, without decoding.
+		u, uri, err = escapedURL(r.Path, joinRawQuery(v))
 	} else {
-		for key, value := range r.QueryStringParameters {
-			v.Add(key, value)
-		}
+		u, uri, err = decodedURL(r.Path, v.Encode())
 	}
-	uri := r.Path
-	if len(r.QueryStringParameters) > 0 {
-		uri = uri + "?" + v.Encode()
+	if err != nil {
+		return nil, invalidEvent("path", err)
 	}
-	u, _ := url.Parse(uri)
-	var contentLength int64
-	var b io.Reader
-	if r.IsBase64Encoded {
-		raw := make([]byte, len(r.Body))
-		n, err := base64.StdEncoding.Decode(raw, []byte(r.Body))
-		if err != nil {
-			return nil, err
-		}
-		contentLength = int64(n)
-		b = bytes.NewReader(raw[0:n])
-	} else {
-		contentLength = int64(len(r.Body))
-		b = strings.NewReader(r.Body)
+	b, contentLength, err := decodeBody(r.Body, r.IsBase64Encoded)
+	if err != nil {
+		return nil, err
 	}
 	req := http.Request{
 		Method:        r.HTTPMethod,
@@ -140,24 +163,47 @@ func (r RequestV1) httpRequest() (*http.Request, error) {
 		Header:        header,
 		ContentLength: contentLength,
 		Body:          io.NopCloser(b),
-		RemoteAddr:    r.RequestContext.Identity["sourceIp"],
+		RemoteAddr:    remoteAddr(r.RequestContext.Identity["sourceIp"]),
 		Host:          host,
 		RequestURI:    uri,
 		URL:           u,
 	}
-	return validateRequest(&req)
+	if r.RequestContext.ELB != nil {
+		// ALB appends the address of the client to X-Forwarded-For.
+		req.RemoteAddr = remoteAddr(lastForwardedFor(header))
+		setRequestOrigin(&req, forwardedProto(header, "http"))
+	} else {
+		setRequestOrigin(&req, forwardedProto(header, "https"))
+	}
+	return validateRequest(withEventContext(&req, eventContext{
+		stage:      r.RequestContext.Stage,
+		domainName: r.RequestContext.DomainName,
+		authorizer: r.RequestContext.Authorizer,
+	}))
 }
 
 // RequestContextV1 represents request contest object (v1.0).
 type RequestContextV1 struct {
-	AccountID    string            `json:"accountId"`
-	APIID        string            `json:"apiId"`
-	HTTPMethod   string            `json:"httpMethod"`
-	Identity     map[string]string `json:"identity"`
-	RequestID    string            `json:"requestId"`
-	ResourceID   string            `json:"resourceId"`
-	ResourcePath string            `json:"resourcePath"`
-	Stage        string            `json:"stage"`
+	AccountID        string                 `json:"accountId"`
+	APIID            string                 `json:"apiId"`
+	Authorizer       map[string]interface{} `json:"authorizer,omitempty"`
+	DomainName       string                 `json:"domainName,omitempty"`
+	HTTPMethod       string                 `json:"httpMethod"`
+	Identity         map[string]string      `json:"identity"`
+	Path             string                 `json:"path,omitempty"`
+	Protocol         string                 `json:"protocol,omitempty"`
+	RequestID        string                 `json:"requestId"`
+	RequestTime      string                 `json:"requestTime,omitempty"`
+	RequestTimeEpoch int64                  `json:"requestTimeEpoch,omitempty"`
+	ResourceID       string                 `json:"resourceId"`
+	ResourcePath     string                 `json:"resourcePath"`
+	Stage            string                 `json:"stage"`
+	ELB              *ELBContext            `json:"elb,omitempty"`
+}
+
+// ELBContext represents request context of Application Load Balancer.
+type ELBContext struct {
+	TargetGroupArn string `json:"targetGroupArn"`
 }
 
 // RequstContext is alias to RequestContextV1
@@ -181,10 +227,11 @@ type RequestV2 struct {
 
 // RequestContextV2 represents request context for v2.0
 type RequestContextV2 struct {
-	AccountID    string `json:"accountId"`
-	APIID        string `json:"apiId"`
-	DomainName   string `json:"domainName"`
-	DomainPrefix string `json:"domainPrefix"`
+	AccountID    string                 `json:"accountId"`
+	APIID        string                 `json:"apiId"`
+	Authorizer   map[string]interface{} `json:"authorizer,omitempty"`
+	DomainName   string                 `json:"domainName"`
+	DomainPrefix string                 `json:"domainPrefix"`
 	HTTP         struct {
 		Method    string `json:"method"`
 		Path      string `json:"path"`
@@ -202,13 +249,13 @@ type RequestContextV2 struct {
 
 func validateRequest(r *http.Request) (*http.Request, error) {
 	if r.Method == "" {
-		return nil, fmt.Errorf("http method is empty")
+		return nil, invalidEvent("httpMethod", errors.New("http method is empty"))
 	}
 	if r.URL == nil {
-		return nil, fmt.Errorf("url is nil")
+		return nil, invalidEvent("path", errors.New("url is nil"))
 	}
 	if r.URL.Path == "" {
-		return nil, fmt.Errorf("url path is empty")
+		return nil, invalidEvent("path", errors.New("url path is empty"))
 	}
 	return r, nil
 }
@@ -227,24 +274,13 @@ func (r RequestV2) httpRequest() (*http.Request, error) {
 	if len(r.Cookies) > 0 {
 		header.Add("Cookie", strings.Join(r.Cookies, "; "))
 	}
-	uri := r.RawPath
-	if r.RawQueryString != "" {
-		uri = uri + "?" + r.RawQueryString
+	u, uri, err := escapedURL(r.RawPath, r.RawQueryString)
+	if err != nil {
+		return nil, invalidEvent("rawPath", err)
 	}
-	u, _ := url.Parse(uri)
-	var contentLength int64
-	var b io.Reader
-	if r.IsBase64Encoded {
-		raw := make([]byte, len(r.Body))
-		n, err := base64.StdEncoding.Decode(raw, []byte(r.Body))
-		if err != nil {
-			return nil, err
-		}
-		contentLength = int64(n)
-		b = bytes.NewReader(raw[0:n])
-	} else {
-		contentLength = int64(len(r.Body))
-		b = strings.NewReader(r.Body)
+	b, contentLength, err := decodeBody(r.Body, r.IsBase64Encoded)
+	if err != nil {
+		return nil, err
 	}
 	pmajor, pminor := parseHTTPProtocol(r.RequestContext.HTTP.Protocol)
 	req := http.Request{
@@ -255,12 +291,17 @@ func (r RequestV2) httpRequest() (*http.Request, error) {
 		Header:        header,
 		ContentLength: contentLength,
 		Body:          io.NopCloser(b),
-		RemoteAddr:    r.RequestContext.HTTP.SourceIP,
+		RemoteAddr:    remoteAddr(r.RequestContext.HTTP.SourceIP),
 		Host:          host,
 		RequestURI:    uri,
 		URL:           u,
 	}
-	return validateRequest(&req)
+	setRequestOrigin(&req, forwardedProto(header, "https"))
+	return validateRequest(withEventContext(&req, eventContext{
+		stage:      r.RequestContext.Stage,
+		domainName: r.RequestContext.DomainName,
+		authorizer: r.RequestContext.Authorizer,
+	}))
 }
 
 func parseHTTPProtocol(s string) (int, int) {
@@ -275,71 +316,3 @@ func parseHTTPProtocol(s string) (int, int) {
 	}
 	return 0, 0
 }
-
-// ToRequestV1 converts *http.Request to RequestV1.
-func ToRequestV1(r *http.Request) (RequestV1, error) {
-	rv1 := RequestV1{
-		Version:                         "1.0",
-		Headers:                         make(map[string]string),
-		PathParameters:                  make(map[string]string),
-		QueryStringParameters:           make(map[string]string),
-		MultiValueQueryStringParameters: make(map[string][]string),
-	}
-	rv1.HTTPMethod = r.Method
-	rv1.Path = r.URL.Path
-	rv1.Headers = make(map[string]string)
-	rv1.Headers["Host"] = r.Host
-	for key := range r.Header {
-		rv1.Headers[key] = r.Header.Get(key)
-	}
-	rv1.MultiValueHeaders = r.Header
-	rv1.MultiValueHeaders["Host"] = []string{r.Host}
-	for key, value := range r.URL.Query() {
-		rv1.QueryStringParameters[key] = value[0]
-		rv1.MultiValueQueryStringParameters[key] = value
-	}
-	rv1.RequestContext.HTTPMethod = r.Method
-	if r.Body != nil {
-		b, _ := io.ReadAll(r.Body)
-		if len(b) > 0 {
-			rv1.Body = base64.StdEncoding.EncodeToString(b)
-			rv1.IsBase64Encoded = true
-		}
-	}
-	return rv1, nil
-}
-
-// ToRequestV2 converts *http.Request to RequestV2.
-func ToRequestV2(r *http.Request) (RequestV2, error) {
-	rv2 := RequestV2{
-		Version:               "2.0",
-		RawPath:               r.URL.Path,
-		RawQueryString:        r.URL.RawQuery,
-		Headers:               make(map[string]string),
-		QueryStringParameters: make(map[string]string),
-		StageVariables:        make(map[string]string),
-	}
-	rv2.RequestContext.HTTP.Method = r.Method
-	rv2.RequestContext.HTTP.Path = r.URL.Path
-	rv2.RequestContext.HTTP.Protocol = r.Proto
-	rv2.Headers["Host"] = r.Host
-	for key, value := range r.Header {
-		if key == "Cookie" {
-			continue
-		}
-		rv2.Headers[key] = strings.Join(value, ",")
-	}
-	rv2.Cookies = append(rv2.Cookies, r.Header.Values("Cookie")...)
-
-	for key, value := range r.URL.Query() {
-		rv2.QueryStringParameters[key] = strings.Join(value, ",")
-	}
-	if r.Body != nil {
-		b, _ := io.ReadAll(r.Body)
-		if len(b) > 0 {
-			rv2.Body = base64.StdEncoding.EncodeToString(b)
-			rv2.IsBase64Encoded = true
-		}
-	}
-	return rv2, nil
-}
@@ -0,0 +1,72 @@
+package ridge
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// RequestLattice represents an HTTP request received by Amazon VPC Lattice. (event structure version 2.0)
+// https://docs.aws.amazon.com/vpc-lattice/latest/ug/lambda-functions.html
+type RequestLattice struct {
+	Version               string                `json:"version"`
+	Path                  string                `json:"path"`
+	Method                string                `json:"method"`
+	Headers               map[string][]string   `json:"headers"`
+	QueryStringParameters map[string][]string   `json:"queryStringParameters"`
+	Body                  string                `json:"body"`
+	IsBase64Encoded       bool                  `json:"isBase64Encoded"`
+	RequestContext        RequestContextLattice `json:"requestContext"`
+}
+
+// RequestContextLattice represents request context of VPC Lattice.
+type RequestContextLattice struct {
+	ServiceNetworkArn string                 `json:"serviceNetworkArn"`
+	ServiceArn        string                 `json:"serviceArn"`
+	TargetGroupArn    string                 `json:"targetGroupArn"`
+	Identity          map[string]interface{} `json:"identity"`
+	Region            string                 `json:"region"`
+	// TimeEpoch is a time of the request in microseconds.
+	TimeEpoch string `json:"timeEpoch"`
+}
+
+// isLatticeEvent reports whether the event is a VPC Lattice event.
+// VPC Lattice events have version "2.0" as well as API Gateway v2, but have method and path instead of rawPath.
+func isLatticeEvent(version, rawPath, method string) bool {
+	return version == "2.0" && rawPath == "" && method != ""
+}
+
+func (r RequestLattice) httpRequest() (*http.Request, error) {
+	header := make(http.Header)
+	for key, values := range r.Headers {
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+	host := header.Get("Host")
+	header.Del("Host")
+
+	u, uri, err := escapedURL(r.Path, url.Values(r.QueryStringParameters).Encode())
+	if err != nil {
+		return nil, invalidEvent("path", err)
+	}
+	b, contentLength, err := decodeBody(r.Body, r.IsBase64Encoded)
+	if err != nil {
+		return nil, err
+	}
+	req := http.Request{
+		Method:        r.Method,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		ContentLength: contentLength,
+		Body:          io.NopCloser(b),
+		RemoteAddr:    remoteAddr(lastForwardedFor(header)),
+		Host:          host,
+		RequestURI:    uri,
+		URL:           u,
+	}
+	setRequestOrigin(&req, forwardedProto(header, "http"))
+	return validateRequest(&req)
+}
@@ -0,0 +1,41 @@
+package ridge_test
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/fujiwara/ridge"
+)
+
+func TestLatticeRequest(t *testing.T) {
+	payload, err := os.ReadFile("test/get-lattice.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := ridge.NewRequest(json.RawMessage(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != "GET" {
+		t.Errorf("unexpected method: %s", req.Method)
+	}
+	if req.URL.Path != "/path/to/example" {
+		t.Errorf("unexpected path: %s", req.URL.Path)
+	}
+	if req.Host != "example-0123456789abcdef.7d67968.vpc-lattice-svcs.us-east-1.on.aws" {
+		t.Errorf("unexpected host: %s", req.Host)
+	}
+	if v := req.URL.Query().Get("foo"); v != "bar baz" {
+		t.Errorf("unexpected query foo: %s", v)
+	}
+	if v := req.URL.Query()["multi"]; len(v) != 2 {
+		t.Errorf("unexpected query multi: %v", v)
+	}
+	if v := req.Header.Values("X-Multi"); len(v) != 2 {
+		t.Errorf("unexpected header X-Multi: %v", v)
+	}
+	if v := req.Header.Get(ridge.PayloadVersionHeaderName); v != "2.0" {
+		t.Errorf("unexpected payload version: %s", v)
+	}
+}
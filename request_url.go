@@ -0,0 +1,94 @@
+package ridge
+
+import (
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// escapedURL returns the URL and the request URI of a path and a query string as sent by the client.
+// URL.RawPath and URL.RawQuery keep them exactly, even if they contain characters which must be escaped (e.g. spaces and UTF-8).
+func escapedURL(escapedPath, rawQuery string) (*url.URL, string, error) {
+	p, err := url.PathUnescape(escapedPath)
+	if err != nil {
+		return nil, "", err
+	}
+	if !strings.HasPrefix(p, "/") {
+		return nil, "", errors.New("path must be absolute")
+	}
+	u := &url.URL{Path: p, RawQuery: rawQuery}
+	if u.EscapedPath() != escapedPath {
+		u.RawPath = escapedPath
+	}
+	uri := escapedPath
+	if rawQuery != "" {
+		uri += "?" + rawQuery
+	}
+	return u, uri, nil
+}
+
+// decodedURL returns the URL and the request URI of a decoded path and an encoded query string.
+func decodedURL(path, rawQuery string) (*url.URL, string, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, "", errors.New("path must be absolute")
+	}
+	u := &url.URL{Path: path, RawQuery: rawQuery}
+	return u, u.RequestURI(), nil
+}
+
+// queryValues merges multi-value and single value query string parameters of events.
+// Multi-value ones are preferred when present.
+func queryValues(multi map[string][]string, single map[string]string) url.Values {
+	v := make(url.Values)
+	if len(multi) > 0 {
+		for key, values := range multi {
+			for _, value := range values {
+				v.Add(key, value)
+			}
+		}
+	} else {
+		for key, value := range single {
+			v.Add(key, value)
+		}
+	}
+	return v
+}
+
+// joinRawQuery joins query string parameters which are not decoded (e.g. ALB), without escaping.
+// The original order of keys is not available in events, so the keys are sorted as url.Values.Encode does.
+func joinRawQuery(v url.Values) string {
+	keys := make([]string, 0, len(v))
+	for key := range v {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, key := range keys {
+		for _, value := range v[key] {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(key)
+			b.WriteByte('=')
+			b.WriteString(value)
+		}
+	}
+	return b.String()
+}
+
+// splitRawQuery splits a query string into parameters without decoding, as ALB does.
+func splitRawQuery(rawQuery string) url.Values {
+	v := make(url.Values)
+	for _, kv := range strings.Split(rawQuery, "&") {
+		if kv == "" {
+			continue
+		}
+		key, value := kv, ""
+		if i := strings.Index(kv, "="); i >= 0 {
+			key, value = kv[:i], kv[i+1:]
+		}
+		v[key] = append(v[key], value)
+	}
+	return v
+}
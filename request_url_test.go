@@ -0,0 +1,128 @@
+package ridge_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fujiwara/ridge"
+)
+
+func TestRequestURLV2(t *testing.T) {
+	tests := []struct {
+		rawPath  string
+		rawQuery string
+		path     string
+		escaped  string
+	}{
+		{"/files/a%2Fb", "sig=abc&b=2&a=1", "/files/a/b", "/files/a%2Fb"},
+		{"/100%25", "q=%E3%81%82+x", "/100%", "/100%25"},
+		{"/hello world", "q=a%20b", "/hello world", "/hello%20world"},
+		{"/マルチバイト", "z=1&a=2", "/マルチバイト", "/%E3%83%9E%E3%83%AB%E3%83%81%E3%83%90%E3%82%A4%E3%83%88"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rawPath, func(t *testing.T) {
+			event := ridge.RequestV2{
+				Version:        "2.0",
+				RawPath:        tt.rawPath,
+				RawQueryString: tt.rawQuery,
+			}
+			event.RequestContext.HTTP.Method = "GET"
+			b, _ := json.Marshal(event)
+			r, err := ridge.NewRequest(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.URL.Path != tt.path {
+				t.Errorf("URL.Path: %s is not expected", r.URL.Path)
+			}
+			if r.URL.EscapedPath() != tt.escaped {
+				t.Errorf("URL.EscapedPath(): %s is not expected", r.URL.EscapedPath())
+			}
+			if r.URL.RawQuery != tt.rawQuery {
+				t.Errorf("URL.RawQuery: %s is not expected", r.URL.RawQuery)
+			}
+			if uri := tt.rawPath + "?" + tt.rawQuery; r.RequestURI != uri {
+				t.Errorf("RequestURI: %s is not expected", r.RequestURI)
+			}
+		})
+	}
+}
+
+func TestRequestURLV1(t *testing.T) {
+	event, _ := json.Marshal(ridge.RequestV1{
+		HTTPMethod: "GET",
+		Path:       "/100% sure/a/b",
+		MultiValueQueryStringParameters: map[string][]string{
+			"q": {"a b", "&"},
+		},
+	})
+	r, err := ridge.NewRequest(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.URL.Path != "/100% sure/a/b" {
+		t.Errorf("URL.Path: %s is not expected", r.URL.Path)
+	}
+	if r.RequestURI != "/100%25%20sure/a/b?q=a+b&q=%26" {
+		t.Errorf("RequestURI: %s is not expected", r.RequestURI)
+	}
+	if q := r.URL.Query()["q"]; len(q) != 2 || q[0] != "a b" || q[1] != "&" {
+		t.Errorf("query: %v is not expected", q)
+	}
+}
+
+func TestRequestURLALB(t *testing.T) {
+	for _, multiValue := range []bool{false, true} {
+		var opts []ridge.ToRequestOption
+		if multiValue {
+			opts = append(opts, ridge.WithMultiValueHeaders())
+		}
+		req := httptest.NewRequest(http.MethodGet, "/files/a%2Fb?sig=a%2Bb%3D&name=%E3%81%82", nil)
+		event, err := ridge.ToRequestALB(req, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Path != "/files/a%2Fb" {
+			t.Errorf("path: %s is not expected", event.Path)
+		}
+		if !multiValue && event.QueryStringParameters["sig"] != "a%2Bb%3D" {
+			t.Errorf("queryStringParameters: %v is not expected", event.QueryStringParameters)
+		}
+		b, _ := json.Marshal(event)
+		r, err := ridge.NewRequest(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.URL.EscapedPath() != "/files/a%2Fb" || r.URL.Path != "/files/a/b" {
+			t.Errorf("URL: %s is not expected", r.URL)
+		}
+		// keys are sorted because events don't have the original order.
+		if r.URL.RawQuery != "name=%E3%81%82&sig=a%2Bb%3D" {
+			t.Errorf("URL.RawQuery: %s is not expected", r.URL.RawQuery)
+		}
+		if v := r.URL.Query().Get("sig"); v != "a+b=" {
+			t.Errorf("sig: %s is not expected", v)
+		}
+	}
+}
+
+func TestToRequestV2RawPath(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/files/a%2Fb?b=2&a=1", nil)
+	event, err := ridge.ToRequestV2(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.RawPath != "/files/a%2Fb" || event.RawQueryString != "b=2&a=1" {
+		t.Errorf("unexpected event: %s %s", event.RawPath, event.RawQueryString)
+	}
+	b, _ := json.Marshal(event)
+	r, err := ridge.NewRequest(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.RequestURI != req.RequestURI {
+		t.Errorf("RequestURI: %s is not expected", r.RequestURI)
+	}
+}
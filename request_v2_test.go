@@ -29,7 +29,7 @@ func TestGetRequestV2(t *testing.T) {
 	if r.Method != "GET" {
 		t.Errorf("Method: %s is not expected", r.Method)
 	}
-	u, _ := url.Parse("/%E3%83%9E%E3%83%AB%E3%83%81%E3%83%90%E3%82%A4%E3%83%88?foo=1&foo=2&bar=3")
+	u, _ := url.Parse("https://abcdefg.execute-api.ap-northeast-1.amazonaws.com/%E3%83%9E%E3%83%AB%E3%83%81%E3%83%90%E3%82%A4%E3%83%88?foo=1&foo=2&bar=3")
 	if r.URL.String() != u.String() {
 		t.Errorf("URL: %s is not expected", r.URL)
 	}
@@ -48,7 +48,7 @@ func TestGetRequestV2(t *testing.T) {
 	if v := r.Header.Get("x-amzn-requestid"); v != "Jl6rIhtwNjMEJLQ=" {
 		t.Errorf("Header[x-amzn-requestid]: %s is not expected", v)
 	}
-	if r.RemoteAddr != "203.0.113.1" {
+	if r.RemoteAddr != "203.0.113.1:0" {
 		t.Errorf("RemoteAddr: %s is not expected", r.RemoteAddr)
 	}
 	// Verify version header is set for v2.0
@@ -74,14 +74,14 @@ func TestPostRequestV2(t *testing.T) {
 	if r.Method != "POST" {
 		t.Errorf("Method: %s is not expected", r.Method)
 	}
-	u, _ := url.Parse("/")
+	u, _ := url.Parse("https://abcdefg.execute-api.ap-northeast-1.amazonaws.com/")
 	if r.URL.String() != u.String() {
 		t.Errorf("URL: %s is not expected", r.URL)
 	}
 	if v := r.FormValue("foo"); v != "bar baz" {
 		t.Errorf("PostFormValue(foo): %s is not expected", v)
 	}
-	if r.RemoteAddr != "203.0.113.1" {
+	if r.RemoteAddr != "203.0.113.1:0" {
 		t.Errorf("RemoteAddr: %s is not expected", r.RemoteAddr)
 	}
 	if v := r.Header.Get("x-amzn-trace-id"); v != "Root=1-5e723db7-6077c85e0d781094f0c83e24" {
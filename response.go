@@ -0,0 +1,128 @@
+package ridge
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// streamingPreludeDelimiter separates a JSON prelude and a body of streaming responses.
+var streamingPreludeDelimiter = []byte{0, 0, 0, 0, 0, 0, 0, 0}
+
+// ParseResponse parses a payload returned by a Lambda function into Response.
+// It accepts responses for API Gateway (v1, v2 and REST API), ALB, and a streaming response with a JSON prelude.
+// A payload without statusCode is treated as a JSON body of 200 OK, as API Gateway HTTP API (v2) does.
+func ParseResponse(payload []byte) (*Response, error) {
+	if i := bytes.Index(payload, streamingPreludeDelimiter); i >= 0 && json.Valid(payload[:i]) {
+		return parseStreamingResponse(payload[:i], payload[i+len(streamingPreludeDelimiter):])
+	}
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &probe); err != nil || probe["statusCode"] == nil {
+		if !json.Valid(payload) {
+			return nil, fmt.Errorf("invalid response payload: %q", truncate(payload, 64))
+		}
+		return &Response{
+			StatusCode: http.StatusOK,
+			Headers:    map[string]string{"Content-Type": "application/json"},
+			Body:       string(payload),
+		}, nil
+	}
+	var res Response
+	if err := json.Unmarshal(payload, &res); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &res, nil
+}
+
+func parseStreamingResponse(prelude, body []byte) (*Response, error) {
+	var p struct {
+		StatusCode int               `json:"statusCode"`
+		Headers    map[string]string `json:"headers"`
+		Cookies    []string          `json:"cookies"`
+	}
+	if err := json.Unmarshal(prelude, &p); err != nil {
+		return nil, fmt.Errorf("failed to decode streaming response prelude: %w", err)
+	}
+	if p.StatusCode == 0 {
+		p.StatusCode = http.StatusOK
+	}
+	return &Response{
+		StatusCode:      p.StatusCode,
+		Headers:         p.Headers,
+		Cookies:         p.Cookies,
+		Body:            base64.StdEncoding.EncodeToString(body),
+		IsBase64Encoded: true,
+	}, nil
+}
+
+// Header returns merged headers of Headers, MultiValueHeaders and Cookies.
+func (r *Response) Header() http.Header {
+	h := make(http.Header)
+	for k, vs := range r.MultiValueHeaders {
+		mergeHeaderValues(h, k, vs)
+	}
+	for k, v := range r.Headers {
+		mergeHeaderValues(h, k, []string{v})
+	}
+	mergeHeaderValues(h, "Set-Cookie", r.Cookies)
+	return h
+}
+
+// mergeHeaderValues adds values to the header, except ones already added from another source.
+// ridge (and other implementations) may set the same values to headers, multiValueHeaders and cookies.
+// Each existing value matches at most one of values, so values repeated in a list (e.g. Set-Cookie, Via) are kept.
+func mergeHeaderValues(h http.Header, key string, values []string) {
+	existing := make(map[string]int)
+	for _, v := range h.Values(key) {
+		existing[v]++
+	}
+	for _, v := range values {
+		if existing[v] > 0 {
+			existing[v]--
+			continue
+		}
+		h.Add(key, v)
+	}
+}
+
+// HTTPResponse converts the response to *http.Response for req.
+func (r *Response) HTTPResponse(req *http.Request) (*http.Response, error) {
+	body := []byte(r.Body)
+	if r.IsBase64Encoded {
+		b, err := base64.StdEncoding.DecodeString(r.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode base64 body: %w", err)
+		}
+		body = b
+	}
+	code := r.StatusCode
+	if code == 0 {
+		code = http.StatusOK
+	}
+	status := r.StatusDescription
+	if status == "" {
+		status = strconv.Itoa(code) + " " + http.StatusText(code)
+	}
+	return &http.Response{
+		Status:        status,
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        r.Header(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func truncate(b []byte, n int) []byte {
+	if len(b) > n {
+		return b[:n]
+	}
+	return b
+}
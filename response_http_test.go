@@ -0,0 +1,145 @@
+package ridge_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/fujiwara/ridge"
+	"github.com/google/go-cmp/cmp"
+)
+
+var parseResponseTests = []struct {
+	name    string
+	payload string
+	status  string
+	code    int
+	header  http.Header
+	body    string
+}{
+	{
+		name:    "v1",
+		payload: `{"statusCode":201,"headers":{"Content-Type":"text/plain"},"multiValueHeaders":{"Content-Type":["text/plain"],"Set-Cookie":["a=1","b=2"]},"body":"Hello","isBase64Encoded":false}`,
+		status:  "201 Created",
+		code:    201,
+		header:  http.Header{"Content-Type": {"text/plain"}, "Set-Cookie": {"a=1", "b=2"}},
+		body:    "Hello",
+	},
+	{
+		name:    "v2",
+		payload: `{"statusCode":200,"headers":{"Content-Type":"image/png","Set-Cookie":"a=1"},"multiValueHeaders":{"Content-Type":["image/png"],"Set-Cookie":["a=1","b=2"]},"cookies":["a=1","b=2"],"body":"iVBORw==","isBase64Encoded":true}`,
+		status:  "200 OK",
+		code:    200,
+		header:  http.Header{"Content-Type": {"image/png"}, "Set-Cookie": {"a=1", "b=2"}},
+		body:    "\x89PNG",
+	},
+	{
+		name:    "repeated values",
+		payload: `{"statusCode":200,"headers":{"Via":"1.1 proxy","Set-Cookie":"a=1"},"multiValueHeaders":{"Via":["1.1 proxy","1.1 proxy"],"Set-Cookie":["a=1","a=1"]},"cookies":["a=1","a=1","b=2","b=2"],"body":""}`,
+		status:  "200 OK",
+		code:    200,
+		header:  http.Header{"Via": {"1.1 proxy", "1.1 proxy"}, "Set-Cookie": {"a=1", "a=1", "b=2", "b=2"}},
+		body:    "",
+	},
+	{
+		name:    "rest",
+		payload: `{"statusCode":404,"headers":{"Content-Type":"application/json"},"body":"{}"}`,
+		status:  "404 Not Found",
+		code:    404,
+		header:  http.Header{"Content-Type": {"application/json"}},
+		body:    "{}",
+	},
+	{
+		name:    "alb",
+		payload: `{"statusCode":503,"statusDescription":"503 Service Unavailable","multiValueHeaders":{"Retry-After":["10"]},"body":"","isBase64Encoded":false}`,
+		status:  "503 Service Unavailable",
+		code:    503,
+		header:  http.Header{"Retry-After": {"10"}},
+		body:    "",
+	},
+	{
+		name:    "v2 without statusCode",
+		payload: `{"message":"hello"}`,
+		status:  "200 OK",
+		code:    200,
+		header:  http.Header{"Content-Type": {"application/json"}},
+		body:    `{"message":"hello"}`,
+	},
+	{
+		name:    "streaming prelude",
+		payload: "{\"statusCode\":202,\"headers\":{\"Content-Type\":\"text/event-stream\"},\"cookies\":[\"a=1\"]}\x00\x00\x00\x00\x00\x00\x00\x00data: 1\n\n",
+		status:  "202 Accepted",
+		code:    202,
+		header:  http.Header{"Content-Type": {"text/event-stream"}, "Set-Cookie": {"a=1"}},
+		body:    "data: 1\n\n",
+	},
+}
+
+func TestParseResponse(t *testing.T) {
+	for _, tt := range parseResponseTests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := ridge.ParseResponse([]byte(tt.payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+			hr, err := res.HTTPResponse(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hr.Status != tt.status || hr.StatusCode != tt.code {
+				t.Errorf("unexpected status: %s", hr.Status)
+			}
+			if d := cmp.Diff(tt.header, hr.Header); d != "" {
+				t.Errorf("unexpected header: %s", d)
+			}
+			b, _ := io.ReadAll(hr.Body)
+			if string(b) != tt.body {
+				t.Errorf("unexpected body: %q", b)
+			}
+			if hr.ContentLength != int64(len(tt.body)) {
+				t.Errorf("unexpected content length: %d", hr.ContentLength)
+			}
+			if hr.Request != req {
+				t.Error("request is not set")
+			}
+		})
+	}
+}
+
+func TestParseResponseInvalid(t *testing.T) {
+	for _, payload := range []string{"", "not json", `{"statusCode":"200"}`} {
+		if _, err := ridge.ParseResponse([]byte(payload)); err == nil {
+			t.Errorf("expected error for %q", payload)
+		}
+	}
+}
+
+func TestResponseRoundTrip(t *testing.T) {
+	w := ridge.NewResponseWriter()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Add("Set-Cookie", "a=1")
+	w.WriteHeader(http.StatusTeapot)
+	w.Write([]byte{0, 1, 2, 3})
+	b, _ := json.Marshal(w.ResponseFor("2.0"))
+
+	res, err := ridge.ParseResponse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hr, err := res.HTTPResponse(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hr.StatusCode != http.StatusTeapot {
+		t.Errorf("unexpected status code: %d", hr.StatusCode)
+	}
+	if v := hr.Header.Values("Set-Cookie"); len(v) != 1 || v[0] != "a=1" {
+		t.Errorf("unexpected Set-Cookie: %v", v)
+	}
+	body, _ := io.ReadAll(hr.Body)
+	if d := cmp.Diff([]byte{0, 1, 2, 3}, body); d != "" {
+		t.Errorf("unexpected body: %s", d)
+	}
+}
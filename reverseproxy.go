@@ -0,0 +1,103 @@
+package ridge
+
+import (
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+)
+
+// NewReverseProxyHandler returns http.Handler that forwards requests to the HTTP server at target.
+// With Ridge, requests converted from events are served by any HTTP server, e.g. an application written in other languages.
+//
+// The path of target is prepended to the request path, and RawPath and RawQuery are forwarded as is.
+// Host is kept as the client requested, and X-Forwarded-For, X-Forwarded-Proto and X-Forwarded-Host are set
+// from RemoteAddr, URL.Scheme and Host of the request. RemoteAddr is not appended twice
+// when X-Forwarded-For of the event already ends with it.
+// Responses are flushed immediately, to stream them with the streaming response mode.
+// When the target is unavailable, 502 Bad Gateway is responded by WriteErrorPage.
+func NewReverseProxyHandler(target *url.URL) http.Handler {
+	return &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			scheme, host := req.URL.Scheme, req.Host
+			req.URL.Scheme = target.Scheme
+			req.URL.Host = target.Host
+			req.URL.Path, req.URL.RawPath = joinURLPath(target, req.URL)
+			if target.RawQuery != "" {
+				if req.URL.RawQuery == "" {
+					req.URL.RawQuery = target.RawQuery
+				} else {
+					req.URL.RawQuery = target.RawQuery + "&" + req.URL.RawQuery
+				}
+			}
+			if _, ok := req.Header["User-Agent"]; !ok {
+				// explicitly disable User-Agent so it's not set to default value
+				req.Header.Set("User-Agent", "")
+			}
+			trimForwardedFor(req.Header, req.RemoteAddr)
+			if req.Header.Get("X-Forwarded-Proto") == "" && scheme != "" {
+				req.Header.Set("X-Forwarded-Proto", scheme)
+			}
+			if req.Header.Get("X-Forwarded-Host") == "" && host != "" {
+				req.Header.Set("X-Forwarded-Host", host)
+			}
+		},
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, req *http.Request, err error) {
+			log.Printf("failed to forward %s %s to %s: %s", req.Method, req.URL.Path, target.Host, err)
+			WriteErrorPage(w, req, http.StatusBadGateway)
+		},
+	}
+}
+
+// trimForwardedFor removes the client IP at the end of X-Forwarded-For, which ReverseProxy appends again.
+// On AWS Lambda, RemoteAddr is taken from X-Forwarded-For or the source IP, which is at the end of X-Forwarded-For as well.
+func trimForwardedFor(h http.Header, remoteAddr string) {
+	ip := sourceIP(remoteAddr)
+	if ip == "" || lastForwardedFor(h) != ip {
+		return
+	}
+	values := h.Values("X-Forwarded-For")
+	last := values[len(values)-1]
+	if i := strings.LastIndex(last, ","); i >= 0 {
+		values[len(values)-1] = strings.TrimSpace(last[:i])
+	} else {
+		values = values[:len(values)-1]
+	}
+	if len(values) == 0 {
+		h.Del("X-Forwarded-For")
+		return
+	}
+	h["X-Forwarded-For"] = values
+}
+
+// joinURLPath joins the paths of a and b, as httputil.NewSingleHostReverseProxy does.
+func joinURLPath(a, b *url.URL) (path, rawpath string) {
+	if a.RawPath == "" && b.RawPath == "" {
+		return singleJoiningSlash(a.Path, b.Path), ""
+	}
+	apath := a.EscapedPath()
+	bpath := b.EscapedPath()
+	aslash := strings.HasSuffix(apath, "/")
+	bslash := strings.HasPrefix(bpath, "/")
+	switch {
+	case aslash && bslash:
+		return a.Path + b.Path[1:], apath + bpath[1:]
+	case !aslash && !bslash:
+		return a.Path + "/" + b.Path, apath + "/" + bpath
+	}
+	return a.Path + b.Path, apath + bpath
+}
+
+func singleJoiningSlash(a, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
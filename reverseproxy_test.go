@@ -0,0 +1,137 @@
+package ridge_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/fujiwara/ridge"
+)
+
+func TestReverseProxyHandler(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Backend", "yes")
+		json.NewEncoder(w).Encode(map[string]string{
+			"uri":   r.RequestURI,
+			"host":  r.Host,
+			"xff":   r.Header.Get("X-Forwarded-For"),
+			"proto": r.Header.Get("X-Forwarded-Proto"),
+			"body":  string(b),
+		})
+	}))
+	defer backend.Close()
+	target, _ := url.Parse(backend.URL + "/base")
+
+	for _, streaming := range []bool{false, true} {
+		t.Run(fmt.Sprintf("streaming=%v", streaming), func(t *testing.T) {
+			r := ridge.New("", "/", ridge.NewReverseProxyHandler(target))
+			r.StreamingResponse = streaming
+			invoker := ridge.NewHandlerInvoker(r)
+			client := &http.Client{
+				Transport: &ridge.Transport{
+					PayloadVersion: "2.0",
+					Invoker: ridge.InvokerFunc(func(ctx context.Context, payload []byte) (*ridge.InvokeOutput, error) {
+						// the source IP of the client, set by API Gateway.
+						var event ridge.RequestV2
+						json.Unmarshal(payload, &event)
+						event.RequestContext.HTTP.SourceIP = "203.0.113.1"
+						payload, _ = json.Marshal(event)
+						return invoker.Invoke(ctx, payload)
+					}),
+				},
+			}
+			req, _ := http.NewRequest(http.MethodPost, "https://api.example.com/files/a%2Fb?b=2&a=1", strings.NewReader("hello"))
+			req.Header.Set("X-Forwarded-Proto", "https")
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != http.StatusOK || res.Header.Get("X-Backend") != "yes" {
+				t.Errorf("unexpected response: %d %v", res.StatusCode, res.Header)
+			}
+			var got map[string]string
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			expected := map[string]string{
+				"uri":   "/base/files/a%2Fb?b=2&a=1",
+				"host":  "api.example.com",
+				"xff":   "203.0.113.1",
+				"proto": "https",
+				"body":  "hello",
+			}
+			for k, v := range expected {
+				if got[k] != v {
+					t.Errorf("%s: %q is not expected, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestReverseProxyHandlerForwardedFor(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strings.Join(r.Header.Values("X-Forwarded-For"), " | "))
+	}))
+	defer backend.Close()
+	target, _ := url.Parse(backend.URL)
+	r := ridge.New("", "/", ridge.NewReverseProxyHandler(target))
+
+	tests := []struct {
+		name    string
+		xff     string
+		toEvent func(*http.Request) (interface{}, error)
+	}{
+		{"v2", "198.51.100.7", func(req *http.Request) (interface{}, error) { return ridge.ToRequestV2(req) }},
+		{"v2 via proxy", "192.0.2.1, 198.51.100.7", func(req *http.Request) (interface{}, error) { return ridge.ToRequestV2(req) }},
+		{"alb", "198.51.100.7", func(req *http.Request) (interface{}, error) { return ridge.ToRequestALB(req) }},
+		{"alb via proxy", "192.0.2.1, 198.51.100.7", func(req *http.Request) (interface{}, error) { return ridge.ToRequestALB(req) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "198.51.100.7:1234"
+			req.Header.Set("X-Forwarded-For", tt.xff)
+			event, err := tt.toEvent(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, _ := json.Marshal(event)
+			res, err := r.Invoke(context.Background(), b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if body := res.(ridge.Response).Body; body != tt.xff {
+				t.Errorf("unexpected X-Forwarded-For: %q, want %q", body, tt.xff)
+			}
+		})
+	}
+}
+
+func TestReverseProxyHandlerUnavailable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, _ := url.Parse("http://" + l.Addr().String())
+	l.Close()
+
+	r := ridge.New("", "/", ridge.NewReverseProxyHandler(target))
+	res, err := transportClient(r).Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusBadGateway {
+		t.Errorf("unexpected status code: %d", res.StatusCode)
+	}
+}
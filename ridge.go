@@ -3,24 +3,24 @@ package ridge
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"io"
 	"log"
 	"mime"
 	"net"
 	"net/http"
 	"os"
-	"os/signal"
 	"strconv"
 	"strings"
 	"sync"
-	"syscall"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-lambda-go/lambdacontext"
-	proxyproto "github.com/pires/go-proxyproto"
 )
 
 // ProxyProtocol is a flag to support PROXY Protocol
@@ -32,9 +32,13 @@ var TextMimeTypes = []string{"image/svg+xml", "application/json", "application/x
 // DefaultContentType is a default content-type when missing in response.
 var DefaultContentType = "text/plain; charset=utf-8"
 
+// DefaultShutdownTimeout is a default timeout to shut down net/http's server gracefully.
+var DefaultShutdownTimeout = 10 * time.Second
+
 // Response represents a response for API Gateway proxy integration.
 type Response struct {
 	StatusCode        int               `json:"statusCode"`
+	StatusDescription string            `json:"statusDescription,omitempty"`
 	Headers           map[string]string `json:"headers"`
 	MultiValueHeaders http.Header       `json:"multiValueHeaders"`
 	Cookies           []string          `json:"cookies,omitempty"`
@@ -86,6 +90,13 @@ func (w *ResponseWriter) WriteHeader(code int) {
 	w.statusCode = code
 }
 
+// reset discards the header and the body written so far.
+func (w *ResponseWriter) reset() {
+	w.Buffer.Reset()
+	w.header = make(http.Header)
+	w.statusCode = http.StatusOK
+}
+
 func (w *ResponseWriter) Response() Response {
 	// Default behavior - include cookies for HTTP API compatibility
 	return w.ResponseFor("2.0")
@@ -198,6 +209,16 @@ func (w *StreamingResponseWriter) Close() error {
 	return nil
 }
 
+// CloseWithError terminates the streaming response with err, e.g. on a panic in the handler.
+// When the header is not written yet, 500 Internal Server Error is responded.
+func (w *StreamingResponseWriter) CloseWithError(err error) error {
+	if !w.isWrittenHeader {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	w.Flush()
+	return w.pipeWriter.CloseWithError(err)
+}
+
 func (w *StreamingResponseWriter) Wait() {
 	<-w.ready
 }
@@ -256,6 +277,96 @@ type Ridge struct {
 	TermHandler       func()
 	ProxyProtocol     bool
 	StreamingResponse bool
+	AccessLog         *AccessLog
+	Metrics           *Metrics
+	PropagateTrace    bool
+	// ProxyProtocolConfig configures PROXY protocol on net/http's server.
+	// When set, PROXY protocol is enabled regardless of ProxyProtocol.
+	ProxyProtocolConfig *ProxyProtocolConfig
+	// OnReady is called with the bound address when net/http's server is ready to accept connections.
+	OnReady func(net.Addr)
+	// ShutdownTimeout is a timeout to wait for in-flight requests on shutting down net/http's server.
+	// default is DefaultShutdownTimeout.
+	ShutdownTimeout time.Duration
+	// DrainOnSIGTERM makes net/http's server drain on SIGTERM: the readiness endpoint turns to 503,
+	// waits DrainDelay, shuts down the server within ShutdownTimeout, and calls ShutdownHooks.
+	DrainOnSIGTERM bool
+	// DrainDelay is a time to wait for load balancers to deregister the target before shutting down.
+	DrainDelay time.Duration
+	// ShutdownHooks are called after net/http's server is shut down.
+	ShutdownHooks []func(context.Context)
+	// Listener is used instead of listening on Address on net/http's server.
+	Listener net.Listener
+	// UnixSocketMode is a file mode of the socket when Address is "unix:/path/to.sock".
+	UnixSocketMode os.FileMode
+	// TLSConfig enables TLS on net/http's server.
+	TLSConfig *tls.Config
+	// TLSCertFile and TLSKeyFile are file names of a certificate and a private key to enable TLS on net/http's server.
+	// Both of them must be set.
+	TLSCertFile string
+	TLSKeyFile  string
+	// H2C enables HTTP/2 over cleartext TCP (h2c) on net/http's server.
+	H2C bool
+	// ReadHeaderTimeout, IdleTimeout and MaxHeaderBytes are passed to http.Server of net/http's server.
+	ReadHeaderTimeout time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// HealthPath, ReadinessPath and InfoPath are paths of the built-in endpoints. e.g. "/healthz", "/readyz" and "/info"
+	// They are mounted outside Prefix, on both of AWS Lambda runtime and net/http's server. An empty path disables the endpoint.
+	// Serve returns an error when any of them is "/", or two of them are the same.
+	// The readiness endpoint responds 503 Service Unavailable while draining or when any of ReadinessChecks fails.
+	HealthPath    string
+	ReadinessPath string
+	InfoPath      string
+	// ReadinessChecks are checks of dependencies for the readiness endpoint.
+	ReadinessChecks []ReadinessCheck
+	// ReadinessCacheTTL is a duration to cache results of ReadinessChecks. default is DefaultReadinessCacheTTL.
+	ReadinessCacheTTL time.Duration
+	// StripStage strips the stage name (requestContext.stage) from the request path on AWS Lambda runtime,
+	// e.g. "/prod/users" to "/users" for requests via execute-api URLs. The "$default" stage is never stripped.
+	StripStage bool
+	// BasePaths are base paths of API Gateway custom domains to strip from the request path, when the path starts with them.
+	// The original path is available by OriginalPath.
+	BasePaths []string
+	// Mounts are handlers mounted on hosts and path prefixes, in addition to Mux on Prefix.
+	Mounts []Mount
+	// NotFoundHandler serves requests which do not match any mounts. default is http.NotFoundHandler.
+	// It is never called when Mux (or any of Mounts) is mounted at "/" for any hosts, because the mount matches all paths.
+	// 404 responses of the mounted handlers themselves are not replaced.
+	NotFoundHandler http.Handler
+	// ColdStartHeader is a header name to set the cold start flag ("true" or "false") to requests and responses.
+	ColdStartHeader string
+	// Middlewares wrap the mounted mux on both of AWS Lambda runtime and net/http's server.
+	// The first one is the outermost. They run inside access logs, metrics and the panic recovery.
+	// They are created once at the first request, as the whole handler is. Do not change the configuration after that.
+	Middlewares []func(http.Handler) http.Handler
+	// BadRequestOnInvalidEvent makes Invoke return a 400 Bad Request response
+	// instead of a Lambda invocation error when an event cannot be decoded.
+	BadRequestOnInvalidEvent bool
+	// AfterInvocation hooks are called after each invocation on AWS Lambda runtime,
+	// before the execution environment is frozen. e.g. flushing telemetry exporters.
+	AfterInvocation []func(context.Context)
+	// TrustedProxies are IP addresses or CIDRs of proxies in front of net/http's server.
+	// X-Forwarded-For, X-Forwarded-Proto and X-Forwarded-Host from them are applied to RemoteAddr, URL.Scheme and Host of requests.
+	TrustedProxies []string
+	// ErrorPage writes error responses of ridge, e.g. 500 Internal Server Error on panics in handlers.
+	// default is WriteErrorPage.
+	ErrorPage func(w http.ResponseWriter, req *http.Request, status int)
+	// FunctionErrorStatus is a status code of responses for invocations failed by FailInvocation on net/http's server.
+	// default is DefaultFunctionErrorStatus.
+	FunctionErrorStatus int
+	// AsyncInvocation treats all invocations as asynchronous ones (InvocationType: Event).
+	// AWS Lambda does not tell the invocation type to functions, so set it when the function is always invoked asynchronously.
+	// On AWS Lambda runtime, an asynchronous invocation responded with 5xx fails, so that Lambda retries it.
+	AsyncInvocation bool
+	// Idempotency enables the idempotency middleware, which responds stored responses for duplicate requests.
+	Idempotency *Idempotency
+
+	draining     int32
+	readiness    readinessCache
+	attempts     attemptCounter
+	handlerOnce  sync.Once
+	builtHandler http.Handler
 }
 
 const (
@@ -298,15 +409,27 @@ func (r *Ridge) setStreamingResponse() {
 }
 
 // RunWithContext runs http handler on AWS Lambda runtime or net/http's server with context.
+// It exits the process when the server fails. Use Serve to handle the error.
 func (r *Ridge) RunWithContext(ctx context.Context) {
+	if err := r.Serve(ctx); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Serve runs http handler on AWS Lambda runtime or net/http's server with context.
+// On net/http's server, it returns nil after the server is shut down gracefully by cancelling ctx.
+func (r *Ridge) Serve(ctx context.Context) error {
+	if err := r.validateHealthPaths(); err != nil {
+		return err
+	}
 	if AsLambdaHandler() {
 		r.setStreamingResponse()
 		r.runAsLambdaHandler(ctx)
-	} else {
-		// If it is not running on the AWS Lambda runtime or running as a Lambda extension,
-		// runs a net/http server.
-		r.runOnNetHTTPServer(ctx)
+		return nil
 	}
+	// If it is not running on the AWS Lambda runtime or running as a Lambda extension,
+	// runs a net/http server.
+	return r.serveOnNetHTTPServer(ctx)
 }
 
 // OnLambdaRuntime returns true if running on AWS Lambda runtime
@@ -328,86 +451,87 @@ func AsLambdaHandler() bool {
 
 func (r *Ridge) mountMux() http.Handler {
 	m := http.NewServeMux()
-	switch {
-	case r.Prefix == "/", r.Prefix == "":
-		m.Handle("/", r.Mux)
-	case !strings.HasSuffix(r.Prefix, "/"):
-		m.Handle(r.Prefix+"/", http.StripPrefix(r.Prefix, r.Mux))
-	default:
-		m.Handle(r.Prefix, http.StripPrefix(strings.TrimSuffix(r.Prefix, "/"), r.Mux))
-	}
+	r.mountHealth(m)
+	m.Handle("/", r.router())
 	return m
 }
 
-func (r *Ridge) runAsLambdaHandler(ctx context.Context) {
-	handler := func(ctx context.Context, event json.RawMessage) (interface{}, error) {
-		req, err := r.RequestBuilder(event)
-		if err != nil {
-			log.Println(err)
-			return nil, err
+// Invoke handles an invocation event as ridge does on AWS Lambda runtime.
+// It returns Response, or *events.LambdaFunctionURLStreamingResponse when StreamingResponse is true.
+func (r *Ridge) Invoke(ctx context.Context, event json.RawMessage) (interface{}, error) {
+	req, err := r.RequestBuilder(event)
+	if err != nil {
+		log.Println(err)
+		if r.BadRequestOnInvalidEvent && (errors.Is(err, ErrInvalidEvent) || errors.Is(err, ErrUnsupportedPayloadVersion)) {
+			return r.badRequest(), nil
 		}
-		if lc, ok := lambdacontext.FromContext(ctx); ok {
-			req.Header.Set("Lambda-Runtime-Aws-Request-Id", lc.AwsRequestID)
-			req.Header.Set("Lambda-Runtime-Invoked-Function-Arn", lc.InvokedFunctionArn)
+		return nil, err
+	}
+	ctx = inheritEventContext(ctx, req)
+	ctx, state := withInvocationState(ctx)
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		req.Header.Set("Lambda-Runtime-Aws-Request-Id", lc.AwsRequestID)
+		req.Header.Set("Lambda-Runtime-Invoked-Function-Arn", lc.InvokedFunctionArn)
+	}
+	if !r.StreamingResponse {
+		w := NewResponseWriter()
+		r.handler().ServeHTTP(w, req.WithContext(ctx))
+		r.afterInvocation(ctx)
+		if fe := state.failure(); fe != nil {
+			return nil, fe.lambdaError()
 		}
-		if !r.StreamingResponse {
-			w := NewResponseWriter()
-			r.mountMux().ServeHTTP(w, req.WithContext(ctx))
-			// Get version from request header
-			version := req.Header.Get(PayloadVersionHeaderName)
-			return w.ResponseFor(version), nil
+		if w.statusCode >= 500 && r.AsyncInvocation {
+			fe := &FunctionError{Type: "HTTPError", Message: strconv.Itoa(w.statusCode) + " " + http.StatusText(w.statusCode)}
+			return nil, fe.lambdaError()
 		}
-		w := NewStreamingResponseWriter()
-		go func() {
-			defer w.Close()
-			r.mountMux().ServeHTTP(w, req.WithContext(ctx))
+		// Get version from request header
+		version := req.Header.Get(PayloadVersionHeaderName)
+		return w.ResponseFor(version), nil
+	}
+	w := NewStreamingResponseWriter()
+	go func() {
+		defer func() {
+			// the response is already streamed, so the failure terminates the stream.
+			if fe := state.failure(); fe != nil {
+				w.CloseWithError(fe.lambdaError())
+				return
+			}
+			w.Close()
 		}()
-		w.Wait()
-		return w.Response(), nil
+		defer r.afterInvocation(ctx)
+		r.handler().ServeHTTP(w, req.WithContext(ctx))
+	}()
+	w.Wait()
+	return w.Response(), nil
+}
+
+// badRequest returns a 400 Bad Request response for an event which cannot be decoded.
+func (r *Ridge) badRequest() interface{} {
+	code := http.StatusBadRequest
+	if !r.StreamingResponse {
+		w := NewResponseWriter()
+		http.Error(w, http.StatusText(code), code)
+		return w.ResponseFor("")
 	}
+	w := NewStreamingResponseWriter()
+	go func() {
+		defer w.Close()
+		http.Error(w, http.StatusText(code), code)
+	}()
+	w.Wait()
+	return w.Response()
+}
+
+func (r *Ridge) runAsLambdaHandler(ctx context.Context) {
 	opts := []lambda.Option{lambda.WithContext(ctx)}
 	if r.TermHandler != nil {
 		opts = append(opts, lambda.WithEnableSIGTERM(r.TermHandler))
 	}
-	lambda.StartWithOptions(handler, opts...)
+	lambda.StartWithOptions(r.Invoke, opts...)
 }
 
-func (r *Ridge) runOnNetHTTPServer(ctx context.Context) {
-	log.Println("starting up with local httpd", r.Address)
-	listener, err := net.Listen("tcp", r.Address)
-	if err != nil {
-		log.Fatalf("couldn't listen to %s: %s", r.Address, err.Error())
-	}
-	if r.ProxyProtocol {
-		log.Println("enables to PROXY protocol")
-		listener = &proxyproto.Listener{Listener: listener}
-	}
-	srv := http.Server{Handler: r.mountMux()}
-	var wg sync.WaitGroup
-	wg.Add(3)
-	ch := make(chan os.Signal, 1)
-	signal.Notify(ch, syscall.SIGTERM)
-	go func() {
-		select {
-		case <-ch:
-		case <-ctx.Done():
-		}
-		if r.TermHandler != nil {
-			r.TermHandler()
-		}
-		wg.Done()
-	}()
-	go func() {
-		defer wg.Done()
-		<-ctx.Done()
-		log.Println("shutting down local httpd", r.Address)
-		srv.Shutdown(ctx)
-	}()
-	if err := srv.Serve(listener); err != nil {
-		if err != http.ErrServerClosed {
-			log.Fatal(err)
-		}
-		wg.Done()
+func (r *Ridge) afterInvocation(ctx context.Context) {
+	for _, hook := range r.AfterInvocation {
+		hook(ctx)
 	}
-	wg.Wait()
 }
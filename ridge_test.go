@@ -1,10 +1,15 @@
 package ridge_test
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/fujiwara/ridge"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestRuntimeEnvironments(t *testing.T) {
@@ -74,3 +79,44 @@ func TestStreamingEnv(t *testing.T) {
 		})
 	}
 }
+
+func TestMiddlewares(t *testing.T) {
+	var order []string
+	mw := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	mux := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "mux")
+	})
+	r := ridge.New(":8080", "/", mux)
+	r.Middlewares = append(r.Middlewares, mw("first"), mw("second"))
+	r.Handler().ServeHTTP(ridge.NewResponseWriter(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if d := cmp.Diff([]string{"first", "second", "mux"}, order); d != "" {
+		t.Errorf("unexpected order: %s", d)
+	}
+}
+
+func TestMiddlewaresCreatedOnce(t *testing.T) {
+	var created int
+	r := ridge.New(":8080", "/", http.NotFoundHandler())
+	r.Middlewares = append(r.Middlewares, func(next http.Handler) http.Handler {
+		created++
+		return next
+	})
+	event := ridge.RequestV2{Version: "2.0", RawPath: "/"}
+	event.RequestContext.HTTP.Method = http.MethodGet
+	b, _ := json.Marshal(event)
+	for i := 0; i < 3; i++ {
+		if _, err := r.Invoke(context.Background(), b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("the middleware is created %d times", created)
+	}
+}
@@ -0,0 +1,294 @@
+// Package ridgetest provides utilities for in-process tests of ridge applications with Lambda invocation events.
+package ridgetest
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fujiwara/ridge"
+)
+
+// Format is a format of invocation events.
+type Format int
+
+const (
+	// FormatV1 is API Gateway HTTP API payload format version 1.0.
+	FormatV1 Format = iota
+	// FormatV2 is API Gateway HTTP API payload format version 2.0.
+	FormatV2
+	// FormatREST is API Gateway REST API.
+	FormatREST
+	// FormatALB is Application Load Balancer.
+	FormatALB
+	// FormatFunctionURL is Lambda function URLs.
+	FormatFunctionURL
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatV1:
+		return "v1"
+	case FormatV2:
+		return "v2"
+	case FormatREST:
+		return "rest"
+	case FormatALB:
+		return "alb"
+	case FormatFunctionURL:
+		return "function-url"
+	}
+	return "unknown"
+}
+
+// Event is a builder of invocation events.
+type Event struct {
+	format     Format
+	method     string
+	path       string
+	host       string
+	header     http.Header
+	query      url.Values
+	cookies    []string
+	authorizer map[string]interface{}
+	sourceIP   string
+	body       string
+	base64     bool
+}
+
+// NewEvent creates a builder of the format.
+func NewEvent(format Format, method, path string) *Event {
+	e := &Event{
+		format:   format,
+		method:   method,
+		path:     path,
+		header:   make(http.Header),
+		query:    make(url.Values),
+		sourceIP: "192.0.2.1",
+	}
+	switch format {
+	case FormatALB:
+		e.host = "ridgetest-123456789.us-east-1.elb.amazonaws.com"
+	case FormatFunctionURL:
+		e.host = "ridgetest.lambda-url.us-east-1.on.aws"
+	default:
+		e.host = "ridgetest.execute-api.us-east-1.amazonaws.com"
+	}
+	return e
+}
+
+// NewV1 creates a builder of API Gateway HTTP API payload format version 1.0.
+func NewV1(method, path string) *Event {
+	return NewEvent(FormatV1, method, path)
+}
+
+// NewV2 creates a builder of API Gateway HTTP API payload format version 2.0.
+func NewV2(method, path string) *Event {
+	return NewEvent(FormatV2, method, path)
+}
+
+// NewREST creates a builder of API Gateway REST API.
+func NewREST(method, path string) *Event {
+	return NewEvent(FormatREST, method, path)
+}
+
+// NewALB creates a builder of Application Load Balancer.
+func NewALB(method, path string) *Event {
+	return NewEvent(FormatALB, method, path)
+}
+
+// NewFunctionURL creates a builder of Lambda function URLs.
+func NewFunctionURL(method, path string) *Event {
+	return NewEvent(FormatFunctionURL, method, path)
+}
+
+// Format returns the format of the event.
+func (e *Event) Format() Format {
+	return e.format
+}
+
+// Host sets a host name.
+func (e *Event) Host(host string) *Event {
+	e.host = host
+	return e
+}
+
+// Header adds a request header.
+func (e *Event) Header(key, value string) *Event {
+	e.header.Add(key, value)
+	return e
+}
+
+// Query adds a query string parameter.
+func (e *Event) Query(key, value string) *Event {
+	e.query.Add(key, value)
+	return e
+}
+
+// Cookie adds a cookie.
+func (e *Event) Cookie(name, value string) *Event {
+	e.cookies = append(e.cookies, (&http.Cookie{Name: name, Value: value}).String())
+	return e
+}
+
+// Authorizer sets an authorizer context. It is ignored for ALB.
+func (e *Event) Authorizer(authorizer map[string]interface{}) *Event {
+	e.authorizer = authorizer
+	return e
+}
+
+// SourceIP sets a source IP address of the client.
+func (e *Event) SourceIP(ip string) *Event {
+	e.sourceIP = ip
+	return e
+}
+
+// Body sets a request body as text.
+func (e *Event) Body(body string) *Event {
+	e.body = body
+	e.base64 = false
+	return e
+}
+
+// Base64Body sets a request body encoded in base64.
+func (e *Event) Base64Body(body []byte) *Event {
+	e.body = base64.StdEncoding.EncodeToString(body)
+	e.base64 = true
+	return e
+}
+
+// JSON returns the event as JSON.
+func (e *Event) JSON() (json.RawMessage, error) {
+	var v interface{}
+	switch e.format {
+	case FormatV2, FormatFunctionURL:
+		v = e.requestV2()
+	default:
+		v = e.requestV1()
+	}
+	return json.Marshal(v)
+}
+
+// MustJSON returns the event as JSON. It panics if the event cannot be encoded.
+func (e *Event) MustJSON() json.RawMessage {
+	b, err := e.JSON()
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func (e *Event) requestV1() ridge.RequestV1 {
+	header := e.header.Clone()
+	header.Set("Host", e.host)
+	if len(e.cookies) > 0 {
+		header.Set("Cookie", strings.Join(e.cookies, "; "))
+	}
+	if header.Get("X-Forwarded-For") == "" {
+		header.Set("X-Forwarded-For", e.sourceIP)
+	}
+	r := ridge.RequestV1{
+		Body:                            e.body,
+		IsBase64Encoded:                 e.base64,
+		HTTPMethod:                      e.method,
+		Path:                            e.path,
+		MultiValueHeaders:               header,
+		Headers:                         make(map[string]string, len(header)),
+		QueryStringParameters:           make(map[string]string, len(e.query)),
+		MultiValueQueryStringParameters: make(map[string][]string, len(e.query)),
+	}
+	for k := range header {
+		r.Headers[k] = header.Get(k)
+	}
+	for k, vs := range e.query {
+		r.QueryStringParameters[k] = vs[len(vs)-1]
+		r.MultiValueQueryStringParameters[k] = vs
+	}
+	if e.format == FormatALB {
+		r.RequestContext.ELB = &ridge.ELBContext{
+			TargetGroupArn: "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/ridgetest/0123456789abcdef",
+		}
+		return r
+	}
+	r.Resource = "$default"
+	r.RequestContext = ridge.RequestContextV1{
+		AccountID:    "123456789012",
+		APIID:        "ridgetest",
+		Authorizer:   e.authorizer,
+		HTTPMethod:   e.method,
+		Identity:     map[string]string{"sourceIp": e.sourceIP, "userAgent": header.Get("User-Agent")},
+		RequestID:    newRequestID(),
+		ResourcePath: "$default",
+		Stage:        "$default",
+	}
+	switch e.format {
+	case FormatV1:
+		r.Version = "1.0"
+	case FormatREST:
+		r.Resource = "/{proxy+}"
+		r.PathParameters = map[string]string{"proxy": strings.TrimPrefix(e.path, "/")}
+		r.RequestContext.ResourcePath = "/{proxy+}"
+		r.RequestContext.Stage = "test"
+	}
+	return r
+}
+
+func (e *Event) requestV2() ridge.RequestV2 {
+	r := ridge.RequestV2{
+		Version:               "2.0",
+		RouteKey:              "$default",
+		RawPath:               e.path,
+		RawQueryString:        e.query.Encode(),
+		Cookies:               e.cookies,
+		Headers:               make(map[string]string, len(e.header)+1),
+		QueryStringParameters: make(map[string]string, len(e.query)),
+		Body:                  e.body,
+		IsBase64Encoded:       e.base64,
+	}
+	keys := make([]string, 0, len(e.header))
+	for k := range e.header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		r.Headers[strings.ToLower(k)] = strings.Join(e.header[k], ",")
+	}
+	r.Headers["host"] = e.host
+	if _, ok := r.Headers["x-forwarded-for"]; !ok {
+		r.Headers["x-forwarded-for"] = e.sourceIP
+	}
+	for k, vs := range e.query {
+		r.QueryStringParameters[k] = strings.Join(vs, ",")
+	}
+	now := time.Now()
+	rc := &r.RequestContext
+	rc.AccountID = "123456789012"
+	rc.APIID = strings.SplitN(e.host, ".", 2)[0]
+	rc.Authorizer = e.authorizer
+	rc.DomainName = e.host
+	rc.DomainPrefix = rc.APIID
+	rc.HTTP.Method = e.method
+	rc.HTTP.Path = e.path
+	rc.HTTP.Protocol = "HTTP/1.1"
+	rc.HTTP.SourceIP = e.sourceIP
+	rc.HTTP.UserAgent = e.header.Get("User-Agent")
+	rc.RequestID = newRequestID()
+	rc.RouteKey = "$default"
+	rc.Stage = "$default"
+	rc.Time = now.UTC().Format("02/Jan/2006:15:04:05 -0700")
+	rc.TimeEpoch = now.UnixNano() / int64(time.Millisecond)
+	return r
+}
+
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	s := hex.EncodeToString(b[:])
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:32]
+}
@@ -0,0 +1,152 @@
+package ridgetest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/fujiwara/ridge"
+)
+
+// InvokedFunctionArn is a function ARN set to the Lambda context of invocations.
+var InvokedFunctionArn = "arn:aws:lambda:us-east-1:123456789012:function:ridgetest"
+
+// Response represents a response of an invocation, normalized as a client of the integration sees it.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	// Body is a decoded response body.
+	Body []byte
+	// Payload is the value returned by ridge. ridge.Response or *events.LambdaFunctionURLStreamingResponse.
+	Payload interface{}
+}
+
+// Invoke invokes handler with the event in buffered response mode.
+func Invoke(handler http.Handler, event json.RawMessage) (*Response, error) {
+	return InvokeRidge(context.Background(), ridge.New("", "/", handler), event)
+}
+
+// InvokeStreaming invokes handler with the event in streaming response mode.
+func InvokeStreaming(handler http.Handler, event json.RawMessage) (*Response, error) {
+	r := ridge.New("", "/", handler)
+	r.StreamingResponse = true
+	return InvokeRidge(context.Background(), r, event)
+}
+
+// InvokeRidge invokes r with the event through the same code path as on AWS Lambda runtime.
+// If ctx has no Lambda context, a Lambda context with a new request ID is set.
+func InvokeRidge(ctx context.Context, r *ridge.Ridge, event json.RawMessage) (*Response, error) {
+	if _, ok := lambdacontext.FromContext(ctx); !ok {
+		ctx = lambdacontext.NewContext(ctx, &lambdacontext.LambdaContext{
+			AwsRequestID:       newRequestID(),
+			InvokedFunctionArn: InvokedFunctionArn,
+		})
+	}
+	payload, err := r.Invoke(ctx, event)
+	if err != nil {
+		return nil, err
+	}
+	var version struct {
+		Version string `json:"version"`
+	}
+	json.Unmarshal(event, &version)
+
+	switch p := payload.(type) {
+	case ridge.Response:
+		return newResponse(p, version.Version == "2.0")
+	case *events.LambdaFunctionURLStreamingResponse:
+		return newStreamingResponse(p)
+	default:
+		return nil, fmt.Errorf("unexpected payload type %T", payload)
+	}
+}
+
+func newResponse(p ridge.Response, v2 bool) (*Response, error) {
+	res := &Response{
+		StatusCode: p.StatusCode,
+		Header:     make(http.Header),
+		Payload:    p,
+	}
+	if v2 {
+		// HTTP API payload version 2.0 uses headers and cookies, and ignores multiValueHeaders.
+		for k, v := range p.Headers {
+			res.Header.Add(k, v)
+		}
+		res.Header.Del("Set-Cookie")
+		for _, c := range p.Cookies {
+			res.Header.Add("Set-Cookie", c)
+		}
+	} else if len(p.MultiValueHeaders) > 0 {
+		for k, vs := range p.MultiValueHeaders {
+			for _, v := range vs {
+				res.Header.Add(k, v)
+			}
+		}
+	} else {
+		for k, v := range p.Headers {
+			res.Header.Add(k, v)
+		}
+	}
+	if p.IsBase64Encoded {
+		b, err := base64.StdEncoding.DecodeString(p.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode base64 body: %w", err)
+		}
+		res.Body = b
+	} else {
+		res.Body = []byte(p.Body)
+	}
+	return res, nil
+}
+
+func newStreamingResponse(p *events.LambdaFunctionURLStreamingResponse) (*Response, error) {
+	res := &Response{
+		StatusCode: p.StatusCode,
+		Header:     make(http.Header),
+		Payload:    p,
+	}
+	for k, v := range p.Headers {
+		res.Header.Add(k, v)
+	}
+	for _, c := range p.Cookies {
+		res.Header.Add("Set-Cookie", c)
+	}
+	b, err := io.ReadAll(p.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read streaming body: %w", err)
+	}
+	res.Body = b
+	return res, nil
+}
+
+// AssertStatus asserts the status code of the response.
+func AssertStatus(t testing.TB, res *Response, code int) {
+	t.Helper()
+	if res.StatusCode != code {
+		t.Errorf("status code: expected %d, got %d", code, res.StatusCode)
+	}
+}
+
+// AssertHeader asserts the header values of the response.
+func AssertHeader(t testing.TB, res *Response, key string, values ...string) {
+	t.Helper()
+	got := res.Header.Values(key)
+	if strings.Join(got, "\n") != strings.Join(values, "\n") {
+		t.Errorf("header %s: expected %q, got %q", key, values, got)
+	}
+}
+
+// AssertBody asserts the decoded body of the response.
+func AssertBody(t testing.TB, res *Response, body string) {
+	t.Helper()
+	if string(res.Body) != body {
+		t.Errorf("body: expected %q, got %q", body, string(res.Body))
+	}
+}
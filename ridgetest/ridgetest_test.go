@@ -0,0 +1,86 @@
+package ridgetest_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/fujiwara/ridge"
+	"github.com/fujiwara/ridge/ridgetest"
+)
+
+var echoHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	b, _ := io.ReadAll(r.Body)
+	c, _ := r.Cookie("session")
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Add("Set-Cookie", "a=1")
+	w.Header().Add("Set-Cookie", "b=2")
+	w.Header().Add("X-Foo", "foo")
+	if a, ok := ridge.AuthorizerFromContext(r.Context()); ok {
+		w.Header().Set("X-Principal", fmt.Sprint(a["principalId"]))
+	}
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, "%s %s?%s host=%s session=%s body=%s", r.Method, r.URL.Path, r.URL.RawQuery, r.Host, c.Value, b)
+})
+
+func TestInvoke(t *testing.T) {
+	formats := []ridgetest.Format{
+		ridgetest.FormatV1,
+		ridgetest.FormatV2,
+		ridgetest.FormatREST,
+		ridgetest.FormatALB,
+		ridgetest.FormatFunctionURL,
+	}
+	invokers := map[string]func(http.Handler, json.RawMessage) (*ridgetest.Response, error){
+		"buffered":  ridgetest.Invoke,
+		"streaming": ridgetest.InvokeStreaming,
+	}
+	for _, format := range formats {
+		for name, invoke := range invokers {
+			t.Run(format.String()+"/"+name, func(t *testing.T) {
+				event := ridgetest.NewEvent(format, http.MethodPost, "/hello").
+					Host("example.com").
+					Header("Content-Type", "application/octet-stream").
+					Query("foo", "bar").
+					Cookie("session", "xyz").
+					Authorizer(map[string]interface{}{"principalId": "user"}).
+					Base64Body([]byte("binary")).
+					MustJSON()
+				res, err := invoke(echoHandler, event)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ridgetest.AssertStatus(t, res, http.StatusCreated)
+				ridgetest.AssertHeader(t, res, "Content-Type", "text/plain")
+				ridgetest.AssertHeader(t, res, "Set-Cookie", "a=1", "b=2")
+				ridgetest.AssertHeader(t, res, "X-Foo", "foo")
+				if format == ridgetest.FormatALB {
+					ridgetest.AssertHeader(t, res, "X-Principal")
+				} else {
+					ridgetest.AssertHeader(t, res, "X-Principal", "user")
+				}
+				ridgetest.AssertBody(t, res, "POST /hello?foo=bar host=example.com session=xyz body=binary")
+			})
+		}
+	}
+}
+
+func TestInvokeFixture(t *testing.T) {
+	event, err := os.ReadFile("../test/get-v2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte{0x89, 'P', 'N', 'G'})
+	})
+	res, err := ridgetest.Invoke(h, event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ridgetest.AssertStatus(t, res, http.StatusOK)
+	ridgetest.AssertBody(t, res, "\x89PNG")
+}
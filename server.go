@@ -0,0 +1,141 @@
+package ridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func (r *Ridge) listen() (net.Listener, error) {
+	listener, err := r.baseListener()
+	if err != nil {
+		return nil, err
+	}
+	if r.ProxyProtocol || r.ProxyProtocolConfig != nil {
+		log.Println("enables to PROXY protocol")
+		c := r.ProxyProtocolConfig
+		if c == nil {
+			c = &ProxyProtocolConfig{}
+		}
+		pl, err := c.listener(listener)
+		if err != nil {
+			listener.Close()
+			return nil, err
+		}
+		listener = pl
+	}
+	return listener, nil
+}
+
+// newServer creates http.Server for net/http's server mode.
+func (r *Ridge) newServer() (*http.Server, error) {
+	if (r.TLSCertFile == "") != (r.TLSKeyFile == "") {
+		return nil, errors.New("both of TLSCertFile and TLSKeyFile must be set to enable TLS")
+	}
+	proxies, err := parseTrustedProxies(r.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	srv := &http.Server{
+		Handler:           withOrigin(r.handler(), proxies),
+		ConnContext:       withProxyProtocolConn,
+		TLSConfig:         r.TLSConfig,
+		ReadHeaderTimeout: r.ReadHeaderTimeout,
+		IdleTimeout:       r.IdleTimeout,
+		MaxHeaderBytes:    r.MaxHeaderBytes,
+	}
+	if r.H2C {
+		log.Println("enables to h2c")
+		h2s := &http2.Server{IdleTimeout: r.IdleTimeout}
+		// ConfigureServer registers h2s to shut down gracefully with srv.
+		if err := http2.ConfigureServer(srv, h2s); err != nil {
+			log.Println("failed to configure HTTP/2:", err)
+		}
+		srv.Handler = h2c.NewHandler(srv.Handler, h2s)
+	}
+	return srv, nil
+}
+
+func (r *Ridge) tlsEnabled() bool {
+	return r.TLSConfig != nil || (r.TLSCertFile != "" && r.TLSKeyFile != "")
+}
+
+// serve serves HTTP (or HTTPS when TLS is enabled) on the listener.
+func (r *Ridge) serve(srv *http.Server, listener net.Listener) error {
+	if r.tlsEnabled() {
+		log.Println("enables to TLS")
+		return srv.ServeTLS(listener, r.TLSCertFile, r.TLSKeyFile)
+	}
+	return srv.Serve(listener)
+}
+
+func (r *Ridge) serveOnNetHTTPServer(ctx context.Context) error {
+	srv, err := r.newServer()
+	if err != nil {
+		return err
+	}
+	listener, err := r.listen()
+	if err != nil {
+		return fmt.Errorf("couldn't listen to %s: %w", r.Address, err)
+	}
+	addr := listener.Addr()
+	log.Println("starting up with local httpd", addr)
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGTERM)
+	defer signal.Stop(ch)
+	done := make(chan struct{}) // closed when the server stopped
+	shutdownErr := make(chan error, 1)
+	go func() {
+		drain := false
+		select {
+		case <-ch:
+			log.Println("received SIGTERM")
+			if r.TermHandler != nil {
+				r.TermHandler()
+			}
+			if r.DrainOnSIGTERM {
+				drain = true
+				break
+			}
+			select {
+			case <-ctx.Done():
+			case <-done:
+				shutdownErr <- nil
+				return
+			}
+		case <-ctx.Done():
+			// ctx may be cancelled by the same SIGTERM (signal.NotifyContext), and it must not skip draining.
+			if r.DrainOnSIGTERM && receivedSignal(ch) {
+				log.Println("received SIGTERM")
+				drain = true
+			}
+			if r.TermHandler != nil {
+				r.TermHandler()
+			}
+		case <-done:
+			shutdownErr <- nil
+			return
+		}
+		shutdownErr <- r.shutdown(srv, drain)
+	}()
+
+	if r.OnReady != nil {
+		r.OnReady(addr)
+	}
+	err = r.serve(srv, listener)
+	close(done)
+	if err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return <-shutdownErr
+}
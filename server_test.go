@@ -0,0 +1,160 @@
+package ridge_test
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fujiwara/ridge"
+	"golang.org/x/net/http2"
+)
+
+func protoHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Proto)
+	})
+}
+
+func startServer(t *testing.T, r *ridge.Ridge) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Listener = l
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- r.Serve(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-errCh; err != nil {
+			t.Error(err)
+		}
+	})
+	return l.Addr().String()
+}
+
+func getBody(t *testing.T, client *http.Client, url string) string {
+	t.Helper()
+	res, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestServerH2C(t *testing.T) {
+	r := ridge.New("", "/", protoHandler())
+	r.H2C = true
+	addr := startServer(t, r)
+
+	client := &http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			},
+		},
+	}
+	if proto := getBody(t, client, "http://"+addr+"/"); proto != "HTTP/2.0" {
+		t.Errorf("unexpected proto: %s", proto)
+	}
+	if proto := getBody(t, http.DefaultClient, "http://"+addr+"/"); proto != "HTTP/1.1" {
+		t.Errorf("unexpected proto: %s", proto)
+	}
+}
+
+func TestServerTLS(t *testing.T) {
+	// borrow a self-signed certificate from httptest
+	ts := httptest.NewTLSServer(protoHandler())
+	defer ts.Close()
+
+	r := ridge.New("", "/", protoHandler())
+	r.TLSConfig = &tls.Config{Certificates: ts.TLS.Certificates}
+	addr := startServer(t, r)
+
+	client := ts.Client()
+	client.Transport.(*http.Transport).ForceAttemptHTTP2 = true
+	if proto := getBody(t, client, "https://"+addr+"/"); proto != "HTTP/2.0" {
+		t.Errorf("unexpected proto: %s", proto)
+	}
+}
+
+func TestServeOnReady(t *testing.T) {
+	r := ridge.New("127.0.0.1:0", "/", protoHandler())
+	ready := make(chan net.Addr, 1)
+	r.OnReady = func(addr net.Addr) { ready <- addr }
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- r.Serve(ctx) }()
+
+	var addr net.Addr
+	select {
+	case addr = <-ready:
+	case err := <-errCh:
+		t.Fatal(err)
+	}
+	if proto := getBody(t, http.DefaultClient, "http://"+addr.String()+"/"); proto != "HTTP/1.1" {
+		t.Errorf("unexpected proto: %s", proto)
+	}
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestServeListenError(t *testing.T) {
+	r := ridge.New("127.0.0.1:-1", "/", protoHandler())
+	if err := r.Serve(context.Background()); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestServeTLSFileMissing(t *testing.T) {
+	r := ridge.New("127.0.0.1:0", "/", protoHandler())
+	r.TLSCertFile = "cert.pem"
+	if err := r.Serve(context.Background()); err == nil {
+		t.Error("expected error without TLSKeyFile")
+	}
+}
+
+func TestServeShutdownTimeout(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	r := ridge.New("127.0.0.1:0", "/", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		close(started)
+		<-release
+	}))
+	r.ShutdownTimeout = 100 * time.Millisecond
+	ready := make(chan net.Addr, 1)
+	r.OnReady = func(addr net.Addr) { ready <- addr }
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- r.Serve(ctx) }()
+	addr := <-ready
+	go http.Get("http://" + addr.String() + "/")
+	<-started
+	cancel()
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve does not return after the shutdown timeout")
+	}
+}
@@ -0,0 +1,376 @@
+package ridge
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// requestTimeFormat is a format of requestContext.requestTime and requestContext.time.
+const requestTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// ToRequestOption is an option of converting *http.Request to events.
+type ToRequestOption func(*toRequestOptions)
+
+type toRequestOptions struct {
+	base64Encoding func(contentType string) bool
+	restoreBody    bool
+	requestID      string
+	time           time.Time
+	routeKey       string
+	stage          string
+	resource       string
+	pathParameters map[string]string
+	targetGroupArn string
+	multiValue     bool
+}
+
+// WithBase64Encoding sets a function which reports whether a body of the content type is encoded in base64.
+// By default, a body is encoded in base64 unless the content type is text (see TextMimeTypes) or a form.
+// A body which is not valid UTF-8 is always encoded in base64.
+func WithBase64Encoding(f func(contentType string) bool) ToRequestOption {
+	return func(o *toRequestOptions) {
+		o.base64Encoding = f
+	}
+}
+
+// WithRestoreBody restores the body of the request after reading it,
+// so the conversion can be used in middlewares.
+func WithRestoreBody() ToRequestOption {
+	return func(o *toRequestOptions) {
+		o.restoreBody = true
+	}
+}
+
+// WithRequestID sets a request ID of the request context.
+// By default, the X-Amzn-RequestId header of the request is used.
+func WithRequestID(id string) ToRequestOption {
+	return func(o *toRequestOptions) {
+		o.requestID = id
+	}
+}
+
+// WithRequestTime sets a time of the request context. By default, the current time is used.
+func WithRequestTime(t time.Time) ToRequestOption {
+	return func(o *toRequestOptions) {
+		o.time = t
+	}
+}
+
+// WithRouteKey sets a route key. default is "$default".
+func WithRouteKey(routeKey string) ToRequestOption {
+	return func(o *toRequestOptions) {
+		o.routeKey = routeKey
+	}
+}
+
+// WithStage sets a stage name. default is "$default" ("Prod" for ToRequestREST).
+func WithStage(stage string) ToRequestOption {
+	return func(o *toRequestOptions) {
+		o.stage = stage
+	}
+}
+
+// WithResource sets a resource path of REST API. default is "/{proxy+}".
+func WithResource(resource string) ToRequestOption {
+	return func(o *toRequestOptions) {
+		o.resource = resource
+	}
+}
+
+// WithPathParameters sets path parameters of REST API.
+// By default, the "proxy" parameter is set to the request path when the resource is "/{proxy+}".
+func WithPathParameters(params map[string]string) ToRequestOption {
+	return func(o *toRequestOptions) {
+		o.pathParameters = params
+	}
+}
+
+// WithTargetGroupArn sets an ARN of the target group of ALB or VPC Lattice.
+func WithTargetGroupArn(arn string) ToRequestOption {
+	return func(o *toRequestOptions) {
+		o.targetGroupArn = arn
+	}
+}
+
+// WithMultiValueHeaders emulates ALB target groups with multi-value headers enabled.
+func WithMultiValueHeaders() ToRequestOption {
+	return func(o *toRequestOptions) {
+		o.multiValue = true
+	}
+}
+
+func newToRequestOptions(r *http.Request, opts []ToRequestOption) *toRequestOptions {
+	o := &toRequestOptions{
+		base64Encoding: defaultBase64Encoding,
+		requestID:      r.Header.Get(RequestIDHeaderName),
+		time:           time.Now(),
+		routeKey:       "$default",
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// stageOr returns the stage name, or def if not set.
+func (o *toRequestOptions) stageOr(def string) string {
+	if o.stage == "" {
+		return def
+	}
+	return o.stage
+}
+
+func defaultBase64Encoding(contentType string) bool {
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		return false
+	}
+	return !isTextMime(contentType)
+}
+
+// readBody reads the body of r and returns it encoded for events.
+func (o *toRequestOptions) readBody(r *http.Request) (string, bool, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return "", false, nil
+	}
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return "", false, err
+	}
+	if o.restoreBody {
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(b))
+	}
+	if len(b) == 0 {
+		return "", false, nil
+	}
+	if o.base64Encoding(r.Header.Get("Content-Type")) || !utf8.Valid(b) {
+		return base64.StdEncoding.EncodeToString(b), true, nil
+	}
+	return string(b), false, nil
+}
+
+// ToRequestV1 converts *http.Request to RequestV1.
+func ToRequestV1(r *http.Request, opts ...ToRequestOption) (RequestV1, error) {
+	return toRequestV1(r, newToRequestOptions(r, opts), "$default")
+}
+
+func toRequestV1(r *http.Request, o *toRequestOptions, stage string) (RequestV1, error) {
+	rv1 := RequestV1{
+		Version:                         "1.0",
+		Resource:                        o.routeKey,
+		Headers:                         make(map[string]string),
+		PathParameters:                  make(map[string]string),
+		QueryStringParameters:           make(map[string]string),
+		MultiValueQueryStringParameters: make(map[string][]string),
+	}
+	rv1.HTTPMethod = r.Method
+	rv1.Path = r.URL.Path
+	rv1.Headers["Host"] = r.Host
+	for key := range r.Header {
+		rv1.Headers[key] = r.Header.Get(key)
+	}
+	rv1.MultiValueHeaders = r.Header.Clone()
+	if rv1.MultiValueHeaders == nil {
+		rv1.MultiValueHeaders = make(http.Header)
+	}
+	rv1.MultiValueHeaders["Host"] = []string{r.Host}
+	for key, value := range r.URL.Query() {
+		// API Gateway sets the last value to queryStringParameters.
+		rv1.QueryStringParameters[key] = value[len(value)-1]
+		rv1.MultiValueQueryStringParameters[key] = value
+	}
+	rv1.RequestContext = RequestContextV1{
+		DomainName: r.Host,
+		HTTPMethod: r.Method,
+		Identity: map[string]string{
+			"sourceIp":  sourceIP(r.RemoteAddr),
+			"userAgent": r.UserAgent(),
+		},
+		Path:             r.URL.Path,
+		Protocol:         r.Proto,
+		RequestID:        o.requestID,
+		RequestTime:      o.time.UTC().Format(requestTimeFormat),
+		RequestTimeEpoch: o.time.UnixNano() / int64(time.Millisecond),
+		ResourcePath:     o.routeKey,
+		Stage:            o.stageOr(stage),
+	}
+	body, isBase64Encoded, err := o.readBody(r)
+	if err != nil {
+		return rv1, err
+	}
+	rv1.Body = body
+	rv1.IsBase64Encoded = isBase64Encoded
+	return rv1, nil
+}
+
+// ToRequestV2 converts *http.Request to RequestV2.
+func ToRequestV2(r *http.Request, opts ...ToRequestOption) (RequestV2, error) {
+	return toRequestV2(r, newToRequestOptions(r, opts))
+}
+
+func toRequestV2(r *http.Request, o *toRequestOptions) (RequestV2, error) {
+	rv2 := RequestV2{
+		Version:               "2.0",
+		RouteKey:              o.routeKey,
+		RawPath:               r.URL.EscapedPath(),
+		RawQueryString:        r.URL.RawQuery,
+		Headers:               make(map[string]string),
+		QueryStringParameters: make(map[string]string),
+		StageVariables:        make(map[string]string),
+	}
+	rv2.RequestContext.HTTP.Method = r.Method
+	rv2.RequestContext.HTTP.Path = r.URL.Path
+	rv2.RequestContext.HTTP.Protocol = r.Proto
+	rv2.RequestContext.HTTP.SourceIP = sourceIP(r.RemoteAddr)
+	rv2.RequestContext.HTTP.UserAgent = r.UserAgent()
+	rv2.RequestContext.DomainName = r.Host
+	rv2.RequestContext.RequestID = o.requestID
+	rv2.RequestContext.RouteKey = o.routeKey
+	rv2.RequestContext.Stage = o.stageOr("$default")
+	rv2.RequestContext.Time = o.time.UTC().Format(requestTimeFormat)
+	rv2.RequestContext.TimeEpoch = o.time.UnixNano() / int64(time.Millisecond)
+	rv2.Headers["Host"] = r.Host
+	for key, value := range r.Header {
+		if key == "Cookie" {
+			continue
+		}
+		rv2.Headers[key] = strings.Join(value, ",")
+	}
+	rv2.Cookies = append(rv2.Cookies, r.Header.Values("Cookie")...)
+
+	for key, value := range r.URL.Query() {
+		rv2.QueryStringParameters[key] = strings.Join(value, ",")
+	}
+	body, isBase64Encoded, err := o.readBody(r)
+	if err != nil {
+		return rv2, err
+	}
+	rv2.Body = body
+	rv2.IsBase64Encoded = isBase64Encoded
+	return rv2, nil
+}
+
+// ToRequestREST converts *http.Request to an event of API Gateway REST API.
+// The resource and the path parameters are set by WithResource and WithPathParameters.
+func ToRequestREST(r *http.Request, opts ...ToRequestOption) (RequestV1, error) {
+	o := newToRequestOptions(r, opts)
+	if o.resource == "" {
+		o.resource = "/{proxy+}"
+	}
+	o.routeKey = o.resource
+	rv1, err := toRequestV1(r, o, "Prod")
+	if err != nil {
+		return rv1, err
+	}
+	// REST API events have no version field.
+	rv1.Version = ""
+	switch {
+	case o.pathParameters != nil:
+		rv1.PathParameters = o.pathParameters
+	case o.resource == "/{proxy+}":
+		rv1.PathParameters = map[string]string{"proxy": strings.TrimPrefix(r.URL.Path, "/")}
+	default:
+		rv1.PathParameters = nil
+	}
+	// requestContext.path of REST API includes the stage name.
+	rv1.RequestContext.Path = "/" + rv1.RequestContext.Stage + r.URL.Path
+	return rv1, nil
+}
+
+// ToRequestALB converts *http.Request to an event of Application Load Balancer.
+// The path and the query string parameters are not decoded, as ALB does.
+// By default, the event has single value headers and query string parameters. Use WithMultiValueHeaders to have multi-value ones.
+func ToRequestALB(r *http.Request, opts ...ToRequestOption) (RequestV1, error) {
+	o := newToRequestOptions(r, opts)
+	rv1 := RequestV1{
+		HTTPMethod: r.Method,
+		Path:       r.URL.EscapedPath(),
+		RequestContext: RequestContextV1{
+			ELB: &ELBContext{TargetGroupArn: o.targetGroupArn},
+		},
+	}
+	header := make(http.Header, len(r.Header)+1)
+	for key, values := range r.Header {
+		header[strings.ToLower(key)] = values
+	}
+	header["host"] = []string{r.Host}
+	// ALB does not decode query string parameters.
+	query := splitRawQuery(r.URL.RawQuery)
+	if o.multiValue {
+		rv1.MultiValueHeaders = header
+		rv1.MultiValueQueryStringParameters = query
+	} else {
+		// ALB uses the last value when multi-value headers are disabled.
+		rv1.Headers = make(map[string]string, len(header))
+		for key, values := range header {
+			rv1.Headers[key] = values[len(values)-1]
+		}
+		rv1.QueryStringParameters = make(map[string]string, len(query))
+		for key, values := range query {
+			rv1.QueryStringParameters[key] = values[len(values)-1]
+		}
+	}
+	body, isBase64Encoded, err := o.readBody(r)
+	if err != nil {
+		return rv1, err
+	}
+	rv1.Body = body
+	rv1.IsBase64Encoded = isBase64Encoded
+	return rv1, nil
+}
+
+// ToRequestFunctionURL converts *http.Request to an event of Lambda function URLs.
+// The route key and the stage are always "$default".
+func ToRequestFunctionURL(r *http.Request, opts ...ToRequestOption) (RequestV2, error) {
+	o := newToRequestOptions(r, opts)
+	o.routeKey = "$default"
+	o.stage = "$default"
+	rv2, err := toRequestV2(r, o)
+	if err != nil {
+		return rv2, err
+	}
+	headers := make(map[string]string, len(rv2.Headers))
+	for key, value := range rv2.Headers {
+		headers[strings.ToLower(key)] = value
+	}
+	rv2.Headers = headers
+	rv2.StageVariables = nil
+	// domainName of function URLs is <url-id>.lambda-url.<region>.on.aws.
+	urlID := strings.SplitN(r.Host, ".", 2)[0]
+	rv2.RequestContext.APIID = urlID
+	rv2.RequestContext.DomainPrefix = urlID
+	return rv2, nil
+}
+
+// ToRequestLattice converts *http.Request to an event of VPC Lattice (event structure version 2.0).
+func ToRequestLattice(r *http.Request, opts ...ToRequestOption) (RequestLattice, error) {
+	o := newToRequestOptions(r, opts)
+	rl := RequestLattice{
+		Version:               "2.0",
+		Path:                  r.URL.EscapedPath(),
+		Method:                r.Method,
+		Headers:               make(map[string][]string, len(r.Header)+1),
+		QueryStringParameters: r.URL.Query(),
+		RequestContext: RequestContextLattice{
+			TargetGroupArn: o.targetGroupArn,
+			TimeEpoch:      strconv.FormatInt(o.time.UnixNano()/int64(time.Microsecond), 10),
+		},
+	}
+	for key, values := range r.Header {
+		rl.Headers[strings.ToLower(key)] = values
+	}
+	rl.Headers["host"] = []string{r.Host}
+	body, isBase64Encoded, err := o.readBody(r)
+	if err != nil {
+		return rl, err
+	}
+	rl.Body = body
+	rl.IsBase64Encoded = isBase64Encoded
+	return rl, nil
+}
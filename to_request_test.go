@@ -0,0 +1,226 @@
+package ridge_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fujiwara/ridge"
+)
+
+func TestToRequestBodyEncoding(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+		opts        []ridge.ToRequestOption
+		base64      bool
+	}{
+		{"text/plain", "hello", nil, false},
+		{"application/json", `{"a":1}`, nil, false},
+		{"application/x-www-form-urlencoded", "a=1", nil, false},
+		{"application/octet-stream", "hello", nil, true},
+		{"text/plain", "\xff\xfe", nil, true},
+		{"text/plain", "hello", []ridge.ToRequestOption{ridge.WithBase64Encoding(func(string) bool { return true })}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rv1, err := ridge.ToRequestV1(req, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rv1.IsBase64Encoded != tt.base64 {
+				t.Errorf("unexpected isBase64Encoded: %v", rv1.IsBase64Encoded)
+			}
+			if !tt.base64 && rv1.Body != tt.body {
+				t.Errorf("unexpected body: %s", rv1.Body)
+			}
+		})
+	}
+}
+
+func TestToRequestRestoreBody(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello"))
+	if _, err := ridge.ToRequestV2(req, ridge.WithRestoreBody()); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(req.Body)
+	if string(b) != "hello" {
+		t.Errorf("body is not restored: %q", b)
+	}
+}
+
+func TestToRequestContext(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	req := httptest.NewRequest(http.MethodGet, "/users?id=1&id=2", nil)
+	req.RemoteAddr = "203.0.113.1:12345"
+	req.Header.Set("User-Agent", "ridge-test")
+	opts := []ridge.ToRequestOption{
+		ridge.WithRequestID("request-id"),
+		ridge.WithRequestTime(now),
+		ridge.WithRouteKey("GET /users"),
+		ridge.WithStage("prod"),
+	}
+
+	rv1, err := ridge.ToRequestV1(req, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc1 := rv1.RequestContext
+	if rc1.Identity["sourceIp"] != "203.0.113.1" || rc1.Identity["userAgent"] != "ridge-test" {
+		t.Errorf("unexpected identity: %v", rc1.Identity)
+	}
+	if rc1.RequestID != "request-id" || rc1.Stage != "prod" || rv1.Resource != "GET /users" {
+		t.Errorf("unexpected request context: %#v", rc1)
+	}
+	if rc1.RequestTime != "02/Jan/2025:03:04:05 +0000" || rc1.RequestTimeEpoch != now.UnixNano()/int64(time.Millisecond) {
+		t.Errorf("unexpected request time: %s %d", rc1.RequestTime, rc1.RequestTimeEpoch)
+	}
+	if rv1.QueryStringParameters["id"] != "2" {
+		t.Errorf("unexpected queryStringParameters: %v", rv1.QueryStringParameters)
+	}
+	if _, ok := req.Header["Host"]; ok {
+		t.Error("the request header is modified")
+	}
+
+	rv2, err := ridge.ToRequestV2(req, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc2 := rv2.RequestContext
+	if rc2.HTTP.SourceIP != "203.0.113.1" || rc2.HTTP.UserAgent != "ridge-test" {
+		t.Errorf("unexpected http context: %#v", rc2.HTTP)
+	}
+	if rc2.RequestID != "request-id" || rc2.Stage != "prod" || rc2.RouteKey != "GET /users" || rv2.RouteKey != "GET /users" {
+		t.Errorf("unexpected request context: %#v", rc2)
+	}
+	if rc2.Time != "02/Jan/2025:03:04:05 +0000" || rc2.TimeEpoch != now.UnixNano()/int64(time.Millisecond) {
+		t.Errorf("unexpected request time: %s %d", rc2.Time, rc2.TimeEpoch)
+	}
+
+	b, _ := json.Marshal(rv2)
+	r, err := ridge.NewRequest(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := r.Header.Get(ridge.RequestIDHeaderName); v != "request-id" {
+		t.Errorf("request ID is not carried: %s", v)
+	}
+}
+
+func TestToRequestConverters(t *testing.T) {
+	converters := map[string]func(*http.Request) (interface{}, error){
+		"v1":   func(r *http.Request) (interface{}, error) { return ridge.ToRequestV1(r) },
+		"v2":   func(r *http.Request) (interface{}, error) { return ridge.ToRequestV2(r) },
+		"rest": func(r *http.Request) (interface{}, error) { return ridge.ToRequestREST(r) },
+		"alb":  func(r *http.Request) (interface{}, error) { return ridge.ToRequestALB(r) },
+		"alb-multi": func(r *http.Request) (interface{}, error) {
+			return ridge.ToRequestALB(r, ridge.WithMultiValueHeaders())
+		},
+		"functionurl": func(r *http.Request) (interface{}, error) { return ridge.ToRequestFunctionURL(r) },
+		"lattice":     func(r *http.Request) (interface{}, error) { return ridge.ToRequestLattice(r) },
+	}
+	for name, convert := range converters {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "https://example.com/users/123?q=a+b", strings.NewReader(`{"name":"ridge"}`))
+			req.Header.Set("Content-Type", "application/json")
+			event, err := convert(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := json.Marshal(event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := ridge.NewRequest(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Method != http.MethodPost || r.URL.Path != "/users/123" || r.Host != "example.com" {
+				t.Errorf("unexpected request: %s %s %s", r.Method, r.Host, r.URL.Path)
+			}
+			if v := r.URL.Query().Get("q"); v != "a b" {
+				t.Errorf("unexpected query: %s", v)
+			}
+			if v := r.Header.Get("Content-Type"); v != "application/json" {
+				t.Errorf("unexpected content type: %s", v)
+			}
+			body, _ := io.ReadAll(r.Body)
+			if string(body) != `{"name":"ridge"}` {
+				t.Errorf("unexpected body: %s", body)
+			}
+		})
+	}
+}
+
+func TestToRequestREST(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/users/123", nil)
+	rv1, err := ridge.ToRequestREST(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rv1.Version != "" || rv1.Resource != "/{proxy+}" || rv1.PathParameters["proxy"] != "users/123" {
+		t.Errorf("unexpected event: %#v", rv1)
+	}
+	if rc := rv1.RequestContext; rc.Stage != "Prod" || rc.Path != "/Prod/users/123" || rc.ResourcePath != "/{proxy+}" {
+		t.Errorf("unexpected request context: %#v", rc)
+	}
+
+	rv1, err = ridge.ToRequestREST(req,
+		ridge.WithResource("/users/{id}"),
+		ridge.WithPathParameters(map[string]string{"id": "123"}),
+		ridge.WithStage("dev"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rv1.Resource != "/users/{id}" || rv1.PathParameters["id"] != "123" || rv1.RequestContext.Path != "/dev/users/123" {
+		t.Errorf("unexpected event: %#v", rv1)
+	}
+}
+
+func TestToRequestALB(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/?a=1&a=2", nil)
+	req.Header.Add("X-Multi", "x")
+	req.Header.Add("X-Multi", "y")
+	arn := "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/ridge/0123456789abcdef"
+	rv1, err := ridge.ToRequestALB(req, ridge.WithTargetGroupArn(arn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rv1.RequestContext.ELB == nil || rv1.RequestContext.ELB.TargetGroupArn != arn {
+		t.Errorf("unexpected request context: %#v", rv1.RequestContext)
+	}
+	if rv1.Headers["x-multi"] != "y" || rv1.QueryStringParameters["a"] != "2" || rv1.MultiValueHeaders != nil {
+		t.Errorf("unexpected single value event: %#v", rv1)
+	}
+
+	rv1, err = ridge.ToRequestALB(req, ridge.WithMultiValueHeaders())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rv1.MultiValueHeaders["x-multi"]) != 2 || len(rv1.MultiValueQueryStringParameters["a"]) != 2 || rv1.Headers != nil {
+		t.Errorf("unexpected multi value event: %#v", rv1)
+	}
+}
+
+func TestToRequestFunctionURL(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "https://abcdefg.lambda-url.us-east-1.on.aws/", nil)
+	req.Header.Set("X-Foo", "bar")
+	rv2, err := ridge.ToRequestFunctionURL(req, ridge.WithStage("prod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc := rv2.RequestContext
+	if rc.DomainPrefix != "abcdefg" || rc.APIID != "abcdefg" || rc.Stage != "$default" || rv2.RouteKey != "$default" {
+		t.Errorf("unexpected request context: %#v", rc)
+	}
+	if rv2.Headers["x-foo"] != "bar" {
+		t.Errorf("unexpected headers: %v", rv2.Headers)
+	}
+}
@@ -0,0 +1,211 @@
+package ridge
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// XRayTraceHeaderName is a header name for AWS X-Ray trace context.
+	XRayTraceHeaderName = "X-Amzn-Trace-Id"
+	// TraceParentHeaderName is a header name for W3C trace context.
+	TraceParentHeaderName = "Traceparent"
+)
+
+// TraceContext represents a trace context which is compatible with both of AWS X-Ray and W3C Trace Context.
+type TraceContext struct {
+	// TraceID is a 32 hex digits trace ID. The first 8 digits are an epoch time on AWS X-Ray.
+	TraceID string
+	// ParentID is a 16 hex digits ID of the parent span (segment).
+	ParentID string
+	// Sampled is a sampling decision.
+	Sampled bool
+}
+
+type traceContextKey struct{}
+
+// TraceContextFromContext returns the TraceContext stored in ctx.
+func TraceContextFromContext(ctx context.Context) (*TraceContext, bool) {
+	tc, ok := ctx.Value(traceContextKey{}).(*TraceContext)
+	return tc, ok
+}
+
+// NewTraceContext generates a new sampled TraceContext.
+func NewTraceContext() *TraceContext {
+	var b [16]byte
+	rand.Read(b[:])
+	binary.BigEndian.PutUint32(b[0:4], uint32(time.Now().Unix()))
+	return &TraceContext{
+		TraceID:  hex.EncodeToString(b[:]),
+		ParentID: newSpanID(),
+		Sampled:  true,
+	}
+}
+
+// ParseXRayTraceHeader parses a X-Amzn-Trace-Id header value.
+// e.g. "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1"
+func ParseXRayTraceHeader(s string) (*TraceContext, error) {
+	tc := &TraceContext{}
+	for _, part := range strings.Split(s, ";") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "Root":
+			r := strings.Split(kv[1], "-")
+			if len(r) != 3 || r[0] != "1" || len(r[1]) != 8 || len(r[2]) != 24 || !isHex(r[1]+r[2]) {
+				return nil, fmt.Errorf("invalid X-Ray trace root: %s", kv[1])
+			}
+			tc.TraceID = strings.ToLower(r[1] + r[2])
+		case "Parent":
+			if len(kv[1]) != 16 || !isHex(kv[1]) {
+				return nil, fmt.Errorf("invalid X-Ray trace parent: %s", kv[1])
+			}
+			tc.ParentID = strings.ToLower(kv[1])
+		case "Sampled":
+			tc.Sampled = kv[1] == "1"
+		}
+	}
+	if tc.TraceID == "" {
+		return nil, fmt.Errorf("X-Ray trace root is not found: %s", s)
+	}
+	return tc, nil
+}
+
+// ParseTraceParent parses a W3C traceparent header value.
+// e.g. "00-5759e988bd862e3fe1be46a994272793-53995c3f42cd8ad8-01"
+func ParseTraceParent(s string) (*TraceContext, error) {
+	p := strings.Split(strings.TrimSpace(s), "-")
+	if len(p) < 4 || len(p[0]) != 2 || p[0] == "ff" || len(p[1]) != 32 || len(p[2]) != 16 || len(p[3]) != 2 {
+		return nil, fmt.Errorf("invalid traceparent: %s", s)
+	}
+	if p[0] == "00" && len(p) != 4 {
+		return nil, fmt.Errorf("invalid traceparent: %s", s)
+	}
+	if !isHex(p[0]+p[1]+p[2]+p[3]) || isZeroHex(p[1]) || isZeroHex(p[2]) {
+		return nil, fmt.Errorf("invalid traceparent: %s", s)
+	}
+	flags, _ := hex.DecodeString(p[3])
+	return &TraceContext{
+		TraceID:  strings.ToLower(p[1]),
+		ParentID: strings.ToLower(p[2]),
+		Sampled:  flags[0]&0x01 == 0x01,
+	}, nil
+}
+
+// XRayTraceID returns the trace ID in AWS X-Ray format. e.g. "1-5759e988-bd862e3fe1be46a994272793"
+// It returns an empty string if TraceID is not 32 hex characters.
+func (tc *TraceContext) XRayTraceID() string {
+	if len(tc.TraceID) != 32 || !isHex(tc.TraceID) {
+		return ""
+	}
+	return "1-" + tc.TraceID[0:8] + "-" + tc.TraceID[8:]
+}
+
+// XRayHeader returns a X-Amzn-Trace-Id header value.
+// It returns an empty string if TraceID is not 32 hex characters.
+func (tc *TraceContext) XRayHeader() string {
+	id := tc.XRayTraceID()
+	if id == "" {
+		return ""
+	}
+	s := "Root=" + id
+	if tc.ParentID != "" {
+		s += ";Parent=" + tc.ParentID
+	}
+	if tc.Sampled {
+		s += ";Sampled=1"
+	} else {
+		s += ";Sampled=0"
+	}
+	return s
+}
+
+// TraceParent returns a W3C traceparent header value.
+func (tc *TraceContext) TraceParent() string {
+	parent := tc.ParentID
+	if parent == "" {
+		parent = newSpanID()
+	}
+	flags := "00"
+	if tc.Sampled {
+		flags = "01"
+	}
+	return "00-" + tc.TraceID + "-" + parent + "-" + flags
+}
+
+func newSpanID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// traceContextFromRequest finds a trace context from the Lambda invocation, the request headers, or generates a new one.
+func traceContextFromRequest(req *http.Request) *TraceContext {
+	// aws-lambda-go stores the trace header of the invocation in the context and _X_AMZN_TRACE_ID.
+	s, _ := req.Context().Value("x-amzn-trace-id").(string)
+	if s == "" && AsLambdaHandler() {
+		s = os.Getenv("_X_AMZN_TRACE_ID")
+	}
+	if s != "" {
+		if tc, err := ParseXRayTraceHeader(s); err == nil {
+			return tc
+		}
+	}
+	if s := req.Header.Get(XRayTraceHeaderName); s != "" {
+		if tc, err := ParseXRayTraceHeader(s); err == nil {
+			return tc
+		}
+	}
+	if s := req.Header.Get(TraceParentHeaderName); s != "" {
+		if tc, err := ParseTraceParent(s); err == nil {
+			return tc
+		}
+	}
+	return NewTraceContext()
+}
+
+func withTraceContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		tc := traceContextFromRequest(req)
+		if tc.ParentID == "" {
+			tc.ParentID = newSpanID()
+		}
+		req.Header.Set(XRayTraceHeaderName, tc.XRayHeader())
+		req.Header.Set(TraceParentHeaderName, tc.TraceParent())
+		ctx := context.WithValue(req.Context(), traceContextKey{}, tc)
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+// traceIDFromRequest returns a X-Ray trace ID of the request for logging.
+func traceIDFromRequest(req *http.Request) string {
+	if tc, ok := TraceContextFromContext(req.Context()); ok {
+		return tc.XRayTraceID()
+	}
+	if tc, err := ParseXRayTraceHeader(req.Header.Get(XRayTraceHeaderName)); err == nil {
+		return tc.XRayTraceID()
+	}
+	return ""
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+func isZeroHex(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
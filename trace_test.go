@@ -0,0 +1,134 @@
+package ridge_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fujiwara/ridge"
+)
+
+func TestParseXRayTraceHeader(t *testing.T) {
+	tc, err := ridge.ParseXRayTraceHeader("Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tc.TraceID != "5759e988bd862e3fe1be46a994272793" || tc.ParentID != "53995c3f42cd8ad8" || !tc.Sampled {
+		t.Errorf("unexpected trace context: %#v", tc)
+	}
+	if s := tc.TraceParent(); s != "00-5759e988bd862e3fe1be46a994272793-53995c3f42cd8ad8-01" {
+		t.Errorf("unexpected traceparent: %s", s)
+	}
+	for _, s := range []string{"", "Root=1-xxx", "Parent=53995c3f42cd8ad8", "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=xyz"} {
+		if _, err := ridge.ParseXRayTraceHeader(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
+
+func TestXRayTraceIDInvalid(t *testing.T) {
+	for _, id := range []string{"", "5759e988", "5759e988bd862e3fe1be46a99427279", "zz59e988bd862e3fe1be46a994272793"} {
+		tc := &ridge.TraceContext{TraceID: id, ParentID: "53995c3f42cd8ad8"}
+		if s := tc.XRayTraceID(); s != "" {
+			t.Errorf("unexpected X-Ray trace ID for %q: %s", id, s)
+		}
+		if s := tc.XRayHeader(); s != "" {
+			t.Errorf("unexpected X-Ray header for %q: %s", id, s)
+		}
+	}
+	tc := &ridge.TraceContext{TraceID: "5759e988bd862e3fe1be46a994272793"}
+	if s := tc.XRayTraceID(); s != "1-5759e988-bd862e3fe1be46a994272793" {
+		t.Errorf("unexpected X-Ray trace ID: %s", s)
+	}
+}
+
+func TestParseTraceParent(t *testing.T) {
+	tc, err := ridge.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tc.Sampled {
+		t.Error("unexpected sampled")
+	}
+	if s := tc.XRayHeader(); s != "Root=1-4bf92f35-77b34da6a3ce929d0e0e4736;Parent=00f067aa0ba902b7;Sampled=0" {
+		t.Errorf("unexpected X-Ray header: %s", s)
+	}
+	for _, s := range []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		if _, err := ridge.ParseTraceParent(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
+
+func TestPropagateTrace(t *testing.T) {
+	var got *ridge.TraceContext
+	var xray, traceparent string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = ridge.TraceContextFromContext(r.Context())
+		xray = r.Header.Get(ridge.XRayTraceHeaderName)
+		traceparent = r.Header.Get(ridge.TraceParentHeaderName)
+	})
+	r := ridge.New(":8080", "/", h)
+	r.PropagateTrace = true
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		header  http.Header
+		traceID string
+	}{
+		{
+			name:    "lambda invocation",
+			ctx:     context.WithValue(context.Background(), "x-amzn-trace-id", "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1"),
+			header:  http.Header{ridge.XRayTraceHeaderName: {"Root=1-5e723e47-2ffda90008b1b60064fac400"}},
+			traceID: "5759e988bd862e3fe1be46a994272793",
+		},
+		{
+			name:    "X-Ray header",
+			ctx:     context.Background(),
+			header:  http.Header{ridge.XRayTraceHeaderName: {"Root=1-5e723e47-2ffda90008b1b60064fac400"}},
+			traceID: "5e723e472ffda90008b1b60064fac400",
+		},
+		{
+			name:    "traceparent",
+			ctx:     context.Background(),
+			header:  http.Header{ridge.TraceParentHeaderName: {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}},
+			traceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name:   "generated",
+			ctx:    context.Background(),
+			header: http.Header{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(tt.ctx)
+			req.Header = tt.header
+			r.Handler().ServeHTTP(ridge.NewResponseWriter(), req)
+			if got == nil {
+				t.Fatal("trace context is not found")
+			}
+			if tt.traceID != "" && got.TraceID != tt.traceID {
+				t.Errorf("unexpected trace ID: %s", got.TraceID)
+			}
+			if len(got.TraceID) != 32 || len(got.ParentID) != 16 {
+				t.Errorf("invalid trace context: %#v", got)
+			}
+			if xray != got.XRayHeader() {
+				t.Errorf("unexpected X-Amzn-Trace-Id: %s", xray)
+			}
+			if !strings.HasPrefix(traceparent, "00-"+got.TraceID+"-"+got.ParentID) {
+				t.Errorf("unexpected traceparent: %s", traceparent)
+			}
+		})
+	}
+}
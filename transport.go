@@ -0,0 +1,231 @@
+package ridge
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda/messages"
+)
+
+// Invoker invokes a Lambda function with a payload.
+// Implement it with the AWS SDK (lambda.Invoke or lambda.InvokeWithResponseStream),
+// or use NewHTTPHandlerInvoker or NewHandlerInvoker for in-process invocations.
+type Invoker interface {
+	Invoke(ctx context.Context, payload []byte) (*InvokeOutput, error)
+}
+
+// InvokerFunc is an adapter to use a function as Invoker.
+type InvokerFunc func(ctx context.Context, payload []byte) (*InvokeOutput, error)
+
+// Invoke calls f(ctx, payload).
+func (f InvokerFunc) Invoke(ctx context.Context, payload []byte) (*InvokeOutput, error) {
+	return f(ctx, payload)
+}
+
+// InvokeOutput represents an output of an invocation.
+type InvokeOutput struct {
+	// Payload is a response payload.
+	Payload []byte
+	// FunctionError is a type of the function error. e.g. "Unhandled". Empty if the invocation succeeded.
+	FunctionError string
+	// Stream is a response stream (a JSON prelude, 8 null bytes delimiter and a body) of an invocation with response streaming.
+	// If Stream is set, Payload is ignored. If FunctionError is set too, Stream is read as the error payload.
+	Stream io.ReadCloser
+}
+
+// FunctionErrorHeaderName is a header name of the function error type in responses of Transport.
+const FunctionErrorHeaderName = "X-Amz-Function-Error"
+
+// maxStreamingPreludeSize is a max size of a JSON prelude (or an error payload) of streaming responses.
+const maxStreamingPreludeSize = 1 << 20
+
+// Transport is an http.RoundTripper that invokes a ridge function through Invoker.
+// A request is converted by ToRequestV1 or ToRequestV2, and the response payload is converted back to *http.Response.
+// A function error is mapped to 502 Bad Gateway.
+type Transport struct {
+	Invoker Invoker
+	// PayloadVersion is a payload version of events. "1.0" (default) or "2.0".
+	PayloadVersion string
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Invoker == nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, errors.New("Transport.Invoker is nil")
+	}
+	// ToRequestV1/V2 must not modify the request of the caller.
+	r := req.Clone(req.Context())
+	var event interface{}
+	var err error
+	switch t.PayloadVersion {
+	case "", "1.0":
+		event, err = ToRequestV1(r)
+	case "2.0":
+		event, err = ToRequestV2(r)
+	default:
+		err = unsupportedPayloadVersionError(t.PayloadVersion)
+	}
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	out, err := t.Invoker.Invoke(req.Context(), payload)
+	if err != nil {
+		return nil, err
+	}
+	if out.FunctionError != "" {
+		if out.Stream != nil {
+			b, err := io.ReadAll(io.LimitReader(out.Stream, maxStreamingPreludeSize))
+			out.Stream.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read function error: %w", err)
+			}
+			return functionErrorResponse(req, out.FunctionError, b), nil
+		}
+		return functionErrorResponse(req, out.FunctionError, out.Payload), nil
+	}
+	if out.Stream != nil {
+		return streamingHTTPResponse(req, out.Stream)
+	}
+	res, err := ParseResponse(out.Payload)
+	if err != nil {
+		return nil, err
+	}
+	return res.HTTPResponse(req)
+}
+
+func functionErrorResponse(req *http.Request, functionError string, payload []byte) *http.Response {
+	code := http.StatusBadGateway
+	h := make(http.Header)
+	h.Set("Content-Type", "application/json")
+	h.Set(FunctionErrorHeaderName, functionError)
+	return &http.Response{
+		Status:        strconv.Itoa(code) + " " + http.StatusText(code),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          io.NopCloser(bytes.NewReader(payload)),
+		ContentLength: int64(len(payload)),
+		Request:       req,
+	}
+}
+
+// streamingHTTPResponse reads a JSON prelude from the stream and returns *http.Response which has the rest of the stream as the body.
+func streamingHTTPResponse(req *http.Request, stream io.ReadCloser) (*http.Response, error) {
+	br := bufio.NewReader(stream)
+	var prelude []byte
+	for !bytes.HasSuffix(prelude, streamingPreludeDelimiter) {
+		b, err := br.ReadByte()
+		if err != nil {
+			stream.Close()
+			if errors.Is(err, io.EOF) {
+				// no prelude. the whole stream is a payload.
+				res, err := ParseResponse(prelude)
+				if err != nil {
+					return nil, err
+				}
+				return res.HTTPResponse(req)
+			}
+			return nil, fmt.Errorf("failed to read streaming response prelude: %w", err)
+		}
+		prelude = append(prelude, b)
+		if len(prelude) > maxStreamingPreludeSize {
+			stream.Close()
+			return nil, fmt.Errorf("streaming response prelude is too large")
+		}
+	}
+	res, err := parseStreamingResponse(prelude[:len(prelude)-len(streamingPreludeDelimiter)], nil)
+	if err != nil {
+		stream.Close()
+		return nil, err
+	}
+	hr, err := res.HTTPResponse(req)
+	if err != nil {
+		stream.Close()
+		return nil, err
+	}
+	hr.ContentLength = -1
+	hr.Body = struct {
+		io.Reader
+		io.Closer
+	}{br, stream}
+	return hr, nil
+}
+
+// errorTypeName returns a type name of err as the Lambda runtime reports.
+func errorTypeName(err error) string {
+	t := reflect.TypeOf(err)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+// NewHTTPHandlerInvoker returns Invoker that invokes handler in-process, as a ridge function serving handler at "/".
+// It is useful for tests of clients using Transport without AWS Lambda.
+func NewHTTPHandlerInvoker(handler http.Handler) Invoker {
+	return NewHandlerInvoker(New("", "/", handler))
+}
+
+// NewHandlerInvoker returns Invoker that invokes r in-process through Ridge.Invoke.
+// It is useful for tests of clients using Transport without AWS Lambda.
+func NewHandlerInvoker(r *Ridge) Invoker {
+	return InvokerFunc(func(ctx context.Context, payload []byte) (*InvokeOutput, error) {
+		res, err := r.Invoke(ctx, payload)
+		if err != nil {
+			fe := FunctionError{Type: errorTypeName(err), Message: err.Error()}
+			var ie messages.InvokeResponse_Error
+			if errors.As(err, &ie) {
+				fe = FunctionError{Type: ie.Type, Message: ie.Message}
+			}
+			b, _ := json.Marshal(fe)
+			return &InvokeOutput{Payload: b, FunctionError: "Unhandled"}, nil
+		}
+		if sr, ok := res.(*events.LambdaFunctionURLStreamingResponse); ok {
+			prelude, err := json.Marshal(struct {
+				StatusCode int               `json:"statusCode"`
+				Headers    map[string]string `json:"headers,omitempty"`
+				Cookies    []string          `json:"cookies,omitempty"`
+			}{sr.StatusCode, sr.Headers, sr.Cookies})
+			if err != nil {
+				return nil, err
+			}
+			// closing the stream closes the body, not to block the handler writing to it.
+			var closer io.Closer = io.NopCloser(nil)
+			if c, ok := sr.Body.(io.Closer); ok {
+				closer = c
+			}
+			return &InvokeOutput{
+				Stream: struct {
+					io.Reader
+					io.Closer
+				}{io.MultiReader(bytes.NewReader(prelude), bytes.NewReader(streamingPreludeDelimiter), sr.Body), closer},
+			}, nil
+		}
+		b, err := json.Marshal(res)
+		if err != nil {
+			return nil, err
+		}
+		return &InvokeOutput{Payload: b}, nil
+	})
+}
@@ -0,0 +1,162 @@
+package ridge_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fujiwara/ridge"
+)
+
+func newTransportTestRidge(streaming bool) *ridge.Ridge {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		http.SetCookie(w, &http.Cookie{Name: "a", Value: "1"})
+		fmt.Fprintf(w, "Hello %s %s", r.FormValue("name"), b)
+	})
+	r := ridge.New("", "/", mux)
+	r.StreamingResponse = streaming
+	return r
+}
+
+func TestTransport(t *testing.T) {
+	for _, version := range []string{"1.0", "2.0"} {
+		for _, streaming := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/streaming=%v", version, streaming), func(t *testing.T) {
+				client := &http.Client{
+					Transport: &ridge.Transport{
+						Invoker:        ridge.NewHandlerInvoker(newTransportTestRidge(streaming)),
+						PayloadVersion: version,
+					},
+				}
+				req, _ := http.NewRequest(http.MethodPost, "http://example.com/hello?name=ridge", strings.NewReader("body"))
+				req.Header.Set("X-Foo", "foo")
+				res, err := client.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer res.Body.Close()
+				b, _ := io.ReadAll(res.Body)
+				if res.StatusCode != http.StatusOK {
+					t.Errorf("unexpected status code: %d", res.StatusCode)
+				}
+				if string(b) != "Hello ridge body" {
+					t.Errorf("unexpected body: %s", b)
+				}
+				if v := res.Header.Get("Content-Type"); v != "text/plain" {
+					t.Errorf("unexpected Content-Type: %s", v)
+				}
+				if v := res.Header.Values("Set-Cookie"); len(v) != 1 || v[0] != "a=1" {
+					t.Errorf("unexpected Set-Cookie: %v", v)
+				}
+				if _, ok := req.Header["Host"]; ok {
+					t.Error("the request header is modified")
+				}
+			})
+		}
+	}
+}
+
+func TestTransportHTTPHandler(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "Hello %s", r.URL.Path)
+	})
+	client := &http.Client{Transport: &ridge.Transport{Invoker: ridge.NewHTTPHandlerInvoker(h)}}
+	res, err := client.Get("http://example.com/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK || string(b) != "Hello /foo" {
+		t.Errorf("unexpected response: %d %s", res.StatusCode, b)
+	}
+}
+
+func TestTransportStreamingCloseEarly(t *testing.T) {
+	done := make(chan struct{})
+	r := ridge.New("", "/", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			fmt.Fprintf(w, "chunk %d\n", i)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	r.StreamingResponse = true
+	res, err := transportClient(r).Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1)
+	if _, err := res.Body.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the handler is blocked after the body is closed")
+	}
+}
+
+func TestTransportNilInvoker(t *testing.T) {
+	client := &http.Client{Transport: &ridge.Transport{}}
+	if _, err := client.Get("http://example.com/"); err == nil {
+		t.Error("expected an error for nil Invoker")
+	}
+}
+
+func TestTransportStreamingFunctionError(t *testing.T) {
+	client := &http.Client{
+		Transport: &ridge.Transport{
+			Invoker: ridge.InvokerFunc(func(ctx context.Context, payload []byte) (*ridge.InvokeOutput, error) {
+				return &ridge.InvokeOutput{
+					Stream:        io.NopCloser(strings.NewReader(`{"errorMessage":"boom","errorType":"errorString"}`)),
+					FunctionError: "Unhandled",
+				}, nil
+			}),
+		},
+	}
+	res, err := client.Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusBadGateway || res.Header.Get(ridge.FunctionErrorHeaderName) != "Unhandled" {
+		t.Errorf("unexpected response: %d %v", res.StatusCode, res.Header)
+	}
+	if string(b) != `{"errorMessage":"boom","errorType":"errorString"}` {
+		t.Errorf("unexpected body: %s", b)
+	}
+}
+
+func TestTransportFunctionError(t *testing.T) {
+	client := &http.Client{
+		Transport: &ridge.Transport{
+			Invoker: ridge.InvokerFunc(func(ctx context.Context, payload []byte) (*ridge.InvokeOutput, error) {
+				return &ridge.InvokeOutput{
+					Payload:       []byte(`{"errorMessage":"boom","errorType":"errorString"}`),
+					FunctionError: "Unhandled",
+				}, nil
+			}),
+		},
+	}
+	res, err := client.Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusBadGateway {
+		t.Errorf("unexpected status code: %d", res.StatusCode)
+	}
+	if v := res.Header.Get(ridge.FunctionErrorHeaderName); v != "Unhandled" {
+		t.Errorf("unexpected function error: %s", v)
+	}
+}